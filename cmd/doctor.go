@@ -1,12 +1,20 @@
 package cmd
 
 import (
+	"errors"
+	"io/fs"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
+
+	"github.com/mt4110/rec-watch/internal/config"
+	"github.com/mt4110/rec-watch/internal/probe"
+	"github.com/mt4110/rec-watch/internal/watcher"
 )
 
 var doctorCmd = &cobra.Command{
@@ -16,6 +24,10 @@ var doctorCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Println("🏥 環境診断を開始します...")
 		hasError := false
+		c := cfg
+		if c == nil {
+			c = config.NewDefault()
+		}
 
 		// 1. ffmpeg check
 		if path, err := exec.LookPath("ffmpeg"); err != nil {
@@ -37,6 +49,22 @@ var doctorCmd = &cobra.Command{
 			}
 		}
 
+		// 1b. ffprobe check: the binary inspect and the converter use
+		// (ffprobeBin, or the one next to a custom ffmpegBin)
+		ffprobeBin := c.FFprobeBin
+		if ffprobeBin == "" {
+			ffprobeBin = probe.BinFromFFmpeg(c.FFmpegBin)
+		}
+		if ffprobeBin == "" {
+			ffprobeBin = "ffprobe"
+		}
+		if path, err := exec.LookPath(ffprobeBin); err != nil {
+			log.Printf("❌ ffprobe が見つかりません (%s)。通常は ffmpeg に同梱されています。", ffprobeBin)
+			hasError = true
+		} else {
+			log.Printf("✅ ffprobe found: %s", path)
+		}
+
 		// 2. terminal-notifier check
 		if path, err := exec.LookPath("terminal-notifier"); err != nil {
 			log.Println("⚠️ terminal-notifier が見つかりません。通知をクリックしてファイルを開く機能が動作しません。 (推奨: `brew install terminal-notifier`)")
@@ -84,6 +112,9 @@ var doctorCmd = &cobra.Command{
 			}
 		}
 
+		// 5. Watch dirs and the watches of a running watcher
+		checkWatchDirs(c)
+
 		if hasError {
 			log.Println("\n❌ いくつかの問題が見つかりました。修正してください。")
 			os.Exit(1)
@@ -93,6 +124,60 @@ var doctorCmd = &cobra.Command{
 	},
 }
 
+// checkWatchDirs reports whether the configured watch dirs exist and, if watch
+// mode is running, the state of its watches (see watcher.WatchStatus).
+func checkWatchDirs(c *config.Config) {
+	for _, dir := range c.WatchDirs {
+		if strings.ContainsAny(dir, "*?[{") {
+			matches, err := watcher.MatchWatchPattern(dir)
+			if err != nil {
+				log.Printf("❌ 監視パターンが不正です: %s (%v)", dir, err)
+			} else {
+				log.Printf("✅ 監視パターン: %s (一致するフォルダ: %d個)", dir, len(matches))
+			}
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil {
+			log.Printf("⚠️ 監視フォルダにアクセスできません: %s (%v)", dir, err)
+		} else if !info.IsDir() {
+			log.Printf("⚠️ 監視フォルダがディレクトリではありません: %s", dir)
+		} else {
+			log.Printf("✅ 監視フォルダ: %s", dir)
+		}
+	}
+
+	st, err := watcher.ReadStatus(c)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Println("ℹ️ 監視モードは起動していません")
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️ 監視モードの状態を読めません: %v", err)
+		return
+	}
+	if !processAlive(st.PID) {
+		log.Printf("⚠️ 監視モードの状態ファイルが残っていますが、プロセス (PID %d) は終了しています: %s", st.PID, watcher.StatusPath(c))
+		return
+	}
+	log.Printf("✅ 監視モード実行中 (PID %d, %s から)", st.PID, st.Started.Format("2006-01-02 15:04"))
+	for _, d := range st.Dirs {
+		if d.State == watcher.StateLost {
+			log.Printf("⚠️ 再接続待ち: %s (%s から, %d回失敗: %s)", d.Path, d.Since.Format("2006-01-02 15:04:05"), d.Attempts, d.Error)
+		} else {
+			log.Printf("✅ 監視中: %s (%s)", d.Path, d.Backend)
+		}
+	}
+}
+
+// processAlive reports whether a process with pid exists.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
 func contains(b []byte, sub []byte) bool {
 	for i := 0; i < len(b)-len(sub)+1; i++ {
 		match := true
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mt4110/rec-watch/internal/convert"
+	"github.com/mt4110/rec-watch/internal/logger"
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+var flagInspectJSON bool
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [files...]",
+	Short: "動画ファイルのメタ情報を表示します",
+	Long:  `ffprobeで動画ファイルを解析し、長さ・解像度・コーデック・フレームレート・音声トラックを表示します。`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Keep stdout clean for JSON consumers (jq etc.)
+		if flagInspectJSON {
+			logger.MuteStdout()
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		prober := convert.New(cfg).Prober
+
+		var infos []*probe.MediaInfo
+		hasError := false
+		for _, path := range args {
+			info, err := prober.Probe(ctx, path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s: %v\n", path, err)
+				hasError = true
+				continue
+			}
+			infos = append(infos, info)
+		}
+
+		if flagInspectJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(infos); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		} else {
+			for _, info := range infos {
+				printMediaInfo(info)
+			}
+		}
+
+		if hasError {
+			os.Exit(1)
+		}
+	},
+}
+
+func printMediaInfo(info *probe.MediaInfo) {
+	const separator = "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"
+	fmt.Println(separator)
+	fmt.Printf("📄 %s\n", info.Path)
+	fmt.Println(separator)
+	fmt.Printf("コンテナ:     %s\n", info.FormatName)
+	fmt.Printf("長さ:         %s\n", formatDuration(info.Duration))
+	fmt.Printf("サイズ:       %s\n", formatBytes(info.Size))
+	if info.BitRate > 0 {
+		fmt.Printf("ビットレート: %d kb/s\n", info.BitRate/1000)
+	}
+
+	if v := info.Video; v != nil {
+		codec := v.Codec
+		if v.Profile != "" {
+			codec += " (" + v.Profile + ")"
+		}
+		fmt.Printf("映像:         %s %dx%d %.2ffps %s\n", codec, v.Width, v.Height, v.FrameRate, v.PixFmt)
+	} else {
+		fmt.Println("映像:         なし")
+	}
+
+	if len(info.Audio) == 0 {
+		fmt.Println("音声:         なし")
+	}
+	for i, a := range info.Audio {
+		line := fmt.Sprintf("%s %dch %dHz", a.Codec, a.Channels, a.SampleRate)
+		if a.Language != "" {
+			line += " (" + a.Language + ")"
+		}
+		fmt.Printf("音声 #%d:      %s\n", i+1, line)
+	}
+	fmt.Println()
+}
+
+func init() {
+	inspectCmd.Flags().BoolVar(&flagInspectJSON, "json", false, "JSON形式で出力する")
+	rootCmd.AddCommand(inspectCmd)
+}
@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/bmatcuk/doublestar/v4"
 	"github.com/spf13/cobra"
@@ -53,8 +57,14 @@ var rootCmd = &cobra.Command{
 		updater.CheckFFmpeg()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
+		// Ctrl+C / launchctl stop: cancel all jobs, kill ffmpeg and clean up partial outputs
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 
 		cvt := convert.New(cfg)
+		if err := cvt.Validate(); err != nil {
+			log.Fatalf("設定エラー: %v", err)
+		}
 
 		// Watch Mode
 		// If --watch is passed, we prioritise watch mode.
@@ -72,8 +82,13 @@ var rootCmd = &cobra.Command{
 			}
 
 			w := watcher.New(cfg, cvt)
+			w.ConfigPath, _ = config.Path()
+			w.Reload = reloadConfig(cmd, args)
 			log.Println("👀 監視モードを開始しました (Ctrl+C で終了)")
-			w.Run() // This blocks
+			if err := w.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Fatalf("監視モードが異常終了しました: %v", err)
+			}
+			log.Println("👋 監視モードを終了しました")
 			return
 		}
 
@@ -194,7 +209,12 @@ var rootCmd = &cobra.Command{
 			return
 		}
 
-		cvt.ProcessFiles(filteredFiles)
+		if err := cvt.ProcessFiles(ctx, filteredFiles); err != nil {
+			if errors.Is(err, context.Canceled) {
+				os.Exit(130)
+			}
+			log.Fatalf("❌ %v", err)
+		}
 	},
 }
 
@@ -219,6 +239,25 @@ var (
 	flagProfile        string
 	flagParallelSplit  bool
 	flagGPU            bool
+	flagCodec          string
+	flagContainer      string
+	flagResolution     string
+	flagUpscale        bool
+	flagNameTemplate   string
+	flagOnConflict     string
+	flagNoRemux        bool
+	flagTargetSize     string
+	flagNoVerify       bool
+	flagVerifyDecode   bool
+	flagQuality        []string
+	flagTargetVMAF     float64
+	flagSplitSegment   int
+	flagAutoSplitDur   string
+	flagAutoSplitSize  string
+	flagChunkRetries   int
+	flagWorkers        []string
+	flagCPUThreads     int
+	flagRecursive      bool
 )
 
 func Execute() {
@@ -237,18 +276,56 @@ func init() {
 	rootCmd.Flags().BoolVar(&flagMute, "mute", false, "音声をミュートする")
 	rootCmd.Flags().StringSliceVar(&flagKeywords, "keywords", []string{}, "ファイル名に含まれるキーワードでフィルタ")
 	rootCmd.Flags().StringSliceVar(&flagIgnoreKeywords, "ignore-keywords", []string{}, "ファイル名に含まれるキーワードを除外") // New
-	rootCmd.Flags().BoolVar(&flagNoPad, "no-pad", false, "1080pにリサイズする際に黒帯を追加しない")
-	rootCmd.Flags().BoolVar(&flagStampPerFile, "stamp-per-file", false, "個別のファイル名にタイムスタンプを追加する")
+	rootCmd.Flags().BoolVar(&flagNoPad, "no-pad", false, "リサイズする際に黒帯を追加しない")
+	rootCmd.Flags().BoolVar(&flagStampPerFile, "stamp-per-file", false, "出力ファイル名に元のファイル名を含める ({date}_{time}_{stem})")
+	rootCmd.Flags().StringVar(&flagNameTemplate, "name-template", "", "出力ファイル名のテンプレート (例: {date}_{time}_{stem}_{profile}.{ext})")
+	rootCmd.Flags().StringVar(&flagOnConflict, "on-conflict", "", "出力ファイルが既に存在する場合の動作 (suffix, skip, overwrite)")
+	rootCmd.Flags().StringVar(&flagTargetSize, "target-size", "", "出力サイズの上限 (例: 25MB, 100MB)。2パスで変換し、必要なら解像度/fpsを下げる")
+	rootCmd.Flags().Float64Var(&flagTargetVMAF, "target-vmaf", 0, "サンプルをエンコードしてこのVMAFを満たす最大のCRFを自動で選ぶ (例: 93)")
+	rootCmd.Flags().BoolVar(&flagNoRemux, "no-remux", false, "条件を満たす動画でも再エンコードする (ストリームコピーを使わない)")
+	rootCmd.Flags().BoolVar(&flagNoVerify, "no-verify", false, "変換後の出力検証 (長さ・ストリームの確認) を行わない")
+	rootCmd.Flags().BoolVar(&flagVerifyDecode, "verify-decode", false, "変換後に出力全体をデコードして検証する (時間がかかる)")
+	rootCmd.Flags().StringSliceVar(&flagQuality, "quality", []string{}, "変換後に元動画と比較して品質を測定する (vmaf, ssim, psnr)")
 	rootCmd.Flags().BoolVar(&flagNoTrash, "no-trash", false, "変換元のファイルをゴミ箱に移動しない")
 	rootCmd.Flags().BoolVar(&flagBatchStamp, "batch-stamp", true, "出力先ディレクトリをタイムスタンプ付きで作成する (default true)")
 	rootCmd.Flags().StringVar(&flagFFmpegBin, "ffmpeg-bin", "", "ffmpegのバイナリパスを明示的に指定する")
 	rootCmd.Flags().IntVar(&flagConcurrent, "concurrent", 0, "並列実行数")
+	rootCmd.Flags().IntVar(&flagCPUThreads, "cpu-threads", 0, "同時に実行される全エンコードで使うCPUスレッド数の上限 (default CPUコア数)")
 	rootCmd.Flags().BoolVar(&flagWatch, "watch", false, "指定したディレクトリを監視して自動変換する")
+	rootCmd.Flags().BoolVar(&flagRecursive, "recursive", false, "監視モードでサブディレクトリ (後から作成されたものを含む) も監視する")
 	rootCmd.Flags().BoolVar(&flagNotify, "notify", true, "変換完了時にデスクトップ通知を送る")
 	rootCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "実行せずにコマンドを表示する")
 	rootCmd.Flags().StringVar(&flagProfile, "profile", "", "使用するプロファイル名")
 	rootCmd.Flags().BoolVar(&flagParallelSplit, "parallel-split", false, "動画を分割して並列変換する（大容量ファイル向け・爆速）")
+	rootCmd.Flags().IntVar(&flagSplitSegment, "split-segment", 0, "分割並列モードのチャンクの長さ (秒, default 300)")
+	rootCmd.Flags().StringVar(&flagAutoSplitDur, "auto-split-duration", "", "この長さ以上の動画は自動で分割並列モードにする (例: 30m)")
+	rootCmd.Flags().StringVar(&flagAutoSplitSize, "auto-split-size", "", "このサイズ以上の動画は自動で分割並列モードにする (例: 2GB)")
+	rootCmd.Flags().IntVar(&flagChunkRetries, "chunk-retries", 0, "分割並列モードで失敗したチャンクを再試行する回数 (default 2)")
+	rootCmd.Flags().StringSliceVar(&flagWorkers, "workers", []string{}, "分割並列モードのチャンクを変換させるワーカーのURL (例: http://mac-mini.local:8765)")
 	rootCmd.Flags().BoolVar(&flagGPU, "gpu", false, "GPU(VideoToolbox)を使用して変換する（超爆速・画質/圧縮率はCPUに劣る）")
+	rootCmd.Flags().StringVar(&flagCodec, "codec", "", "映像コーデック (libx264, libx265, libsvtav1, libaom-av1, libvpx-vp9)")
+	rootCmd.Flags().StringVar(&flagResolution, "resolution", "", "出力解像度 (720p, 1080p, 1440p, 4k, vertical, source, 1920x1080, 1280x など)")
+	rootCmd.Flags().BoolVar(&flagUpscale, "upscale", false, "出力解像度より小さい動画も拡大する")
+	rootCmd.Flags().StringVar(&flagContainer, "container", "", "出力コンテナ (mp4, mkv, webm / 省略時はコーデックに合わせる)")
+}
+
+// reloadConfig returns the watcher's Reload func: it builds the config the way
+// the command did (config file, then flags, then the watch targets).
+func reloadConfig(cmd *cobra.Command, watchTargets []string) func() (*config.Config, error) {
+	return func() (*config.Config, error) {
+		c, err := config.Load()
+		if err != nil {
+			return nil, err
+		}
+		updateConfigFromFlags(cmd, c)
+		if len(watchTargets) > 0 {
+			c.WatchDirs = watchTargets
+		}
+		if len(c.WatchDirs) == 0 {
+			c.WatchDirs = []string{"."}
+		}
+		return c, nil
+	}
 }
 
 func updateConfigFromFlags(cmd *cobra.Command, c *config.Config) {
@@ -265,7 +342,26 @@ func updateConfigFromFlags(cmd *cobra.Command, c *config.Config) {
 			if entry.Preset != "" {
 				c.Preset = entry.Preset
 			}
-			log.Printf("ℹ️ プロファイル '%s' を適用しました (CRF: %d, Preset: %s)", flagProfile, c.CRF, c.Preset)
+			if entry.Codec != "" {
+				c.Codec = entry.Codec
+			}
+			if entry.Container != "" {
+				c.Container = entry.Container
+			}
+			if entry.Resolution != "" {
+				c.Resolution = entry.Resolution
+			}
+			if entry.NameTemplate != "" {
+				c.NameTemplate = entry.NameTemplate
+			}
+			if entry.TargetSize != "" {
+				c.TargetSize = entry.TargetSize
+			}
+			if entry.TargetVMAF > 0 {
+				c.TargetVMAF = entry.TargetVMAF
+			}
+			c.ProfileName = flagProfile
+			log.Printf("ℹ️ プロファイル '%s' を適用しました (CRF: %d, Preset: %s, Codec: %s)", flagProfile, c.CRF, c.Preset, c.Codec)
 		} else {
 			log.Printf("⚠️ プロファイル '%s' は見つかりませんでした。デフォルト設定を使用します。", flagProfile)
 		}
@@ -320,9 +416,66 @@ func updateConfigFromFlags(cmd *cobra.Command, c *config.Config) {
 	if flags.Changed("parallel-split") {
 		c.ParallelSplit = flagParallelSplit
 	}
+	if flags.Changed("split-segment") {
+		c.SplitSegmentSec = flagSplitSegment
+	}
+	if flags.Changed("auto-split-duration") {
+		c.AutoSplitDuration = flagAutoSplitDur
+	}
+	if flags.Changed("auto-split-size") {
+		c.AutoSplitSize = flagAutoSplitSize
+	}
+	if flags.Changed("chunk-retries") {
+		c.ChunkRetries = flagChunkRetries
+	}
+	if flags.Changed("recursive") {
+		c.Recursive = flagRecursive
+	}
+	if flags.Changed("cpu-threads") {
+		c.CPUThreads = flagCPUThreads
+	}
+	if flags.Changed("workers") {
+		c.Workers = flagWorkers
+	}
 	if flags.Changed("gpu") {
 		c.GPU = flagGPU
 	}
+	if flags.Changed("codec") {
+		c.Codec = flagCodec
+	}
+	if flags.Changed("container") {
+		c.Container = flagContainer
+	}
+	if flags.Changed("resolution") {
+		c.Resolution = flagResolution
+	}
+	if flags.Changed("upscale") {
+		c.Upscale = flagUpscale
+	}
+	if flags.Changed("name-template") {
+		c.NameTemplate = flagNameTemplate
+	}
+	if flags.Changed("on-conflict") {
+		c.OnConflict = flagOnConflict
+	}
+	if flags.Changed("no-remux") {
+		c.NoRemux = flagNoRemux
+	}
+	if flags.Changed("target-size") {
+		c.TargetSize = flagTargetSize
+	}
+	if flags.Changed("no-verify") {
+		c.NoVerify = flagNoVerify
+	}
+	if flags.Changed("verify-decode") {
+		c.VerifyDecode = flagVerifyDecode
+	}
+	if flags.Changed("quality") {
+		c.Quality = flagQuality
+	}
+	if flags.Changed("target-vmaf") {
+		c.TargetVMAF = flagTargetVMAF
+	}
 
 	// Watch logic overlap
 	if flagWatch {
@@ -14,14 +14,45 @@ import (
 )
 
 type LogEntry struct {
-	Type          string  `json:"type"`
-	Input         string  `json:"input"`
-	Output        string  `json:"output"`
-	DurationSec   float64 `json:"duration_sec"`
-	OriginalSize  int64   `json:"original_size"`
-	ConvertedSize int64   `json:"converted_size"`
-	SizeDiff      int64   `json:"size_diff"`
-	Timestamp     string  `json:"timestamp"`
+	Type             string  `json:"type"`
+	Input            string  `json:"input"`
+	Output           string  `json:"output"`
+	Mode             string  `json:"mode"`
+	DurationSec      float64 `json:"duration_sec"`
+	MediaDurationSec float64 `json:"media_duration_sec"`
+	OriginalSize     int64   `json:"original_size"`
+	ConvertedSize    int64   `json:"converted_size"`
+	SizeDiff         int64   `json:"size_diff"`
+	Timestamp        string  `json:"timestamp"`
+	Quality          *struct {
+		VMAF float64 `json:"vmaf"`
+		SSIM float64 `json:"ssim"`
+		PSNR float64 `json:"psnr"`
+	} `json:"quality"`
+	CRFSearch *struct {
+		CRF     int  `json:"crf"`
+		Reached bool `json:"reached"`
+	} `json:"crf_search"`
+}
+
+// average is the mean of the positive values added (0 = not recorded).
+type average struct {
+	sum float64
+	n   int
+}
+
+func (a *average) add(v float64) {
+	if v > 0 {
+		a.sum += v
+		a.n++
+	}
+}
+
+func (a average) value() float64 {
+	if a.n == 0 {
+		return 0
+	}
+	return a.sum / float64(a.n)
 }
 
 var statsCmd = &cobra.Command{
@@ -48,6 +79,13 @@ var statsCmd = &cobra.Command{
 		var totalDiff int64
 		var totalCount int
 		var totalDuration float64
+		// Only entries with a probed media duration count towards the speed ratio
+		var totalMediaDuration float64
+		var totalProbedDuration float64
+		modeCounts := map[string]int{}
+		var vmaf, ssim, psnr average
+		var searchCRF average
+		var searchMissed int
 
 		// For verification output mostly
 		scanner := bufio.NewScanner(f)
@@ -70,6 +108,24 @@ var statsCmd = &cobra.Command{
 				totalCount++
 				totalDiff += entry.SizeDiff
 				totalDuration += entry.DurationSec
+				if entry.Mode != "" {
+					modeCounts[entry.Mode]++
+				}
+				if entry.MediaDurationSec > 0 {
+					totalMediaDuration += entry.MediaDurationSec
+					totalProbedDuration += entry.DurationSec
+				}
+				if entry.CRFSearch != nil {
+					searchCRF.add(float64(entry.CRFSearch.CRF))
+					if !entry.CRFSearch.Reached {
+						searchMissed++
+					}
+				}
+				if entry.Quality != nil {
+					vmaf.add(entry.Quality.VMAF)
+					ssim.add(entry.Quality.SSIM)
+					psnr.add(entry.Quality.PSNR)
+				}
 			}
 		}
 
@@ -80,6 +136,28 @@ var statsCmd = &cobra.Command{
 		fmt.Printf("総変換数:       %d 本\n", totalCount)
 		fmt.Printf("合計削減サイズ: %s\n", formatBytes(totalDiff))
 		fmt.Printf("合計処理時間:   %s\n", formatDuration(totalDuration))
+		if totalMediaDuration > 0 {
+			fmt.Printf("合計動画時間:   %s\n", formatDuration(totalMediaDuration))
+			if totalProbedDuration > 0 {
+				fmt.Printf("平均変換速度:   %.1fx (実時間比)\n", totalMediaDuration/totalProbedDuration)
+			}
+		}
+		if len(modeCounts) > 0 {
+			fmt.Printf("変換方式:       再エンコード %d / 分割 %d / リマックス %d / 音声コピー %d / 映像コピー %d / 目標サイズ %d\n",
+				modeCounts["encode"], modeCounts["split"], modeCounts["remux"], modeCounts["copy_audio"], modeCounts["copy_video"], modeCounts["target_size"])
+		}
+		if vmaf.n > 0 {
+			fmt.Printf("平均VMAF:       %.2f (%d 本)\n", vmaf.value(), vmaf.n)
+		}
+		if ssim.n > 0 {
+			fmt.Printf("平均SSIM:       %.4f (%d 本)\n", ssim.value(), ssim.n)
+		}
+		if psnr.n > 0 {
+			fmt.Printf("平均PSNR:       %.2f dB (%d 本)\n", psnr.value(), psnr.n)
+		}
+		if searchCRF.n > 0 {
+			fmt.Printf("CRF自動選択:    %d 本 (平均CRF %.1f / 目標VMAF未達 %d 本)\n", searchCRF.n, searchCRF.value(), searchMissed)
+		}
 		if totalCount > 0 {
 			fmt.Printf("平均削減率:     %.1f MB/本\n", float64(totalDiff)/float64(totalCount)/1024/1024)
 		}
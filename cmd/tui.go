@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
@@ -39,14 +43,23 @@ var tuiCmd = &cobra.Command{
 
 		// Dependencies
 		cvt := convert.New(cfg)
+		if err := cvt.Validate(); err != nil {
+			fmt.Printf("設定エラー: %v\n", err)
+			os.Exit(1)
+		}
 		eventChan := make(chan interface{}, 100)
 
 		w := watcher.New(cfg, cvt)
 		w.EventChan = eventChan
+		w.ConfigPath, _ = config.Path()
+		w.Reload = reloadConfig(cmd, nil)
 
-		// Run Watcher in BG
+		// Run Watcher in BG (stopped when the TUI quits or on SIGTERM)
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+		defer stop()
+		watcherDone := make(chan error, 1)
 		go func() {
-			w.Run()
+			watcherDone <- w.Run(ctx)
 		}()
 
 		// Initialize TUI Model
@@ -54,7 +67,15 @@ var tuiCmd = &cobra.Command{
 
 		// Start Bubble Tea Program
 		p := tea.NewProgram(m, tea.WithAltScreen())
-		if _, err := p.Run(); err != nil {
+		_, err := p.Run()
+
+		// Kill running ffmpeg processes and wait for cleanup before exiting
+		stop()
+		if werr := <-watcherDone; werr != nil && !errors.Is(werr, context.Canceled) {
+			fmt.Printf("監視モードが異常終了しました: %v\n", werr)
+		}
+
+		if err != nil {
 			fmt.Printf("Alas, there's been an error: %v", err)
 			os.Exit(1)
 		}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mt4110/rec-watch/internal/remote"
+)
+
+var (
+	flagWorkerListen string
+	flagWorkerSlots  int
+	flagWorkerToken  string
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "分割並列モードのチャンクを変換するワーカーとして待ち受けます",
+	Long: `他のマシンの rec-watch (--workers / config の workers) から送られたチャンクを変換して返すHTTPサーバーを起動します。
+LAN内の別マシンで公開する場合は --listen 0.0.0.0:8765 と --token を指定してください (トークンなしではローカル以外で待ち受けできません)。`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		token := cfg.WorkerToken
+		if cmd.Flags().Changed("token") {
+			token = flagWorkerToken
+		}
+		slots := cfg.Concurrent
+		if cmd.Flags().Changed("slots") {
+			slots = flagWorkerSlots
+		}
+
+		// Any ffmpeg encode on this machine is open to whoever can connect
+		if token == "" && !isLoopback(flagWorkerListen) {
+			log.Fatalf("❌ ローカル以外で待ち受けるには --token (config: workerToken) の指定が必要です: %s", flagWorkerListen)
+		}
+
+		server := remote.NewServer(cfg.FFmpegBin, token, slots)
+		srv := &http.Server{
+			Addr:    flagWorkerListen,
+			Handler: server.Handler(),
+			// Requests share ctx, so that Ctrl+C also kills running encodes
+			BaseContext: func(net.Listener) context.Context { return ctx },
+		}
+		shutdownDone := make(chan struct{})
+		go func() {
+			defer close(shutdownDone)
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+
+		log.Printf("🌐 ワーカーを起動しました: http://%s (%d並列)", flagWorkerListen, max(slots, 1))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("❌ ワーカーを起動できません: %v", err)
+		}
+		<-shutdownDone
+		log.Println("👋 ワーカーを停止しました")
+	},
+}
+
+// isLoopback reports whether a listen address only accepts local connections.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func init() {
+	workerCmd.Flags().StringVar(&flagWorkerListen, "listen", remote.DefaultListen, "待ち受けるアドレス")
+	workerCmd.Flags().IntVar(&flagWorkerSlots, "slots", 0, "同時に変換するチャンク数 (省略時は concurrent)")
+	workerCmd.Flags().StringVar(&flagWorkerToken, "token", "", "認証トークン (省略時は config の workerToken)")
+	rootCmd.AddCommand(workerCmd)
+}
@@ -4,14 +4,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Profile struct {
-	CRF    int    `yaml:"crf"`
-	Preset string `yaml:"preset"`
+	CRF          int     `yaml:"crf"`
+	Preset       string  `yaml:"preset"`
+	Codec        string  `yaml:"codec"`
+	Container    string  `yaml:"container"`
+	Resolution   string  `yaml:"resolution"`
+	NameTemplate string  `yaml:"nameTemplate"`
+	TargetSize   string  `yaml:"targetSize"`
+	TargetVMAF   float64 `yaml:"targetVmaf"`
 }
 
 type Config struct {
@@ -29,6 +37,7 @@ type Config struct {
 	NoTrash        bool               `yaml:"noTrash"`
 	BatchStamp     bool               `yaml:"batchStamp"`
 	FFmpegBin      string             `yaml:"ffmpegBin"`
+	FFprobeBin     string             `yaml:"ffprobeBin"`
 	Concurrent     int                `yaml:"concurrent"`
 	Notify         bool               `yaml:"notify"`
 	LogFile        string             `yaml:"logFile"`
@@ -36,6 +45,76 @@ type Config struct {
 	Profiles       map[string]Profile `yaml:"profiles"`
 	ParallelSplit  bool               `yaml:"parallelSplit"`
 	GPU            bool               `yaml:"gpu"`
+	// Codec is the ffmpeg video encoder (libx264, libx265, libsvtav1, libaom-av1, libvpx-vp9)
+	Codec string `yaml:"codec"`
+	// Container is mp4, mkv or webm. Empty = default for the codec
+	Container string `yaml:"container"`
+	// CodecCRF overrides the CRF mapped from CRF per encoder (e.g. libx265: 26)
+	CodecCRF map[string]int `yaml:"codecCrf"`
+	// Resolution is the output size: 720p, 1080p, 1440p, 4k, vertical, source,
+	// WxH (canvas), Wx / xH (max width / height only)
+	Resolution string `yaml:"resolution"`
+	// Upscale allows enlarging sources smaller than Resolution
+	Upscale bool `yaml:"upscale"`
+	// NameTemplate is the output file name, e.g. "{date}_{time}_{stem}_{profile}.{ext}"
+	NameTemplate string `yaml:"nameTemplate"`
+	// OnConflict decides what happens if the output exists: suffix (default), skip, overwrite
+	OnConflict string `yaml:"onConflict"`
+	// NoRemux forces a full re-encode even if the input already meets the target
+	NoRemux bool `yaml:"noRemux"`
+	// TargetSize caps the output size with a two-pass encode, e.g. "25MB"
+	TargetSize string `yaml:"targetSize"`
+	// NoVerify skips checking the output before the source is trashed
+	NoVerify bool `yaml:"noVerify"`
+	// VerifyDecode additionally decodes the whole output with ffmpeg (slow)
+	VerifyDecode bool `yaml:"verifyDecode"`
+	// VerifyTolerance is the allowed input/output duration difference in seconds (default 2)
+	VerifyTolerance float64 `yaml:"verifyTolerance"`
+	// Quality lists metrics measured against the source after each conversion (vmaf, ssim, psnr)
+	Quality []string `yaml:"quality"`
+	// TargetVMAF picks the highest CRF whose sample encodes reach this VMAF (e.g. 93)
+	TargetVMAF float64 `yaml:"targetVmaf"`
+	// SplitSegmentSec is the chunk length of split mode in seconds (default 300)
+	SplitSegmentSec int `yaml:"splitSegmentSec"`
+	// AutoSplitDuration enables split mode for inputs at least this long, e.g. "30m"
+	AutoSplitDuration string `yaml:"autoSplitDuration"`
+	// AutoSplitSize enables split mode for inputs at least this large, e.g. "2GB"
+	AutoSplitSize string `yaml:"autoSplitSize"`
+	// StateDir keeps resumable job data (split work dirs). Default: ~/.local/state/rec-watch
+	StateDir string `yaml:"stateDir"`
+	// ChunkRetries is how often a failed split chunk is retried (with backoff)
+	ChunkRetries int `yaml:"chunkRetries"`
+	// CPUThreads is the CPU thread budget shared by all running encodes (0 = number of CPUs)
+	CPUThreads int `yaml:"cpuThreads"`
+	// Recursive also watches subdirectories of watchDirs, including ones created later
+	Recursive bool `yaml:"recursive"`
+	// IgnoreDirs are doublestar patterns (full path or directory name) of subdirectories not watched recursively
+	IgnoreDirs []string `yaml:"ignoreDirs"`
+	// NoScan disables the scan for unconverted files when watch mode starts
+	NoScan bool `yaml:"noScan"`
+	// ScanInterval repeats that scan periodically, e.g. "10m" ("" = on startup only)
+	ScanInterval string `yaml:"scanInterval"`
+	// StableWindow is how long a new file's size and mtime must stay unchanged before it is converted (default "3s")
+	StableWindow string `yaml:"stableWindow"`
+	// CheckMoov additionally waits until MP4/MOV files contain their moov atom
+	CheckMoov bool `yaml:"checkMoov"`
+	// WatchBackend selects how watchDirs are watched: "auto" (default: polling on
+	// NFS/SMB/FUSE, fsnotify elsewhere), "fsnotify" or "poll"
+	WatchBackend string `yaml:"watchBackend"`
+	// PollDirs are watchDirs that are always polled, whatever watchBackend says
+	PollDirs []string `yaml:"pollDirs"`
+	// PollInterval is how often polled directories are listed (default "5s")
+	PollInterval string `yaml:"pollInterval"`
+	// GlobInterval is how often glob patterns in watchDirs are expanded again (default "10s")
+	GlobInterval string `yaml:"globInterval"`
+	// WatchWorkers is the number of files watch mode converts at once (0 = concurrent)
+	WatchWorkers int `yaml:"watchWorkers"`
+	// Workers are URLs of rec-watch worker instances that encode split chunks, e.g. "http://mac-mini.local:8765"
+	Workers []string `yaml:"workers"`
+	// WorkerToken authenticates against workers (and is required by rec-watch worker when set)
+	WorkerToken string `yaml:"workerToken"`
+	// ProfileName is the profile applied via --profile (used by {profile})
+	ProfileName string `yaml:"-"`
 }
 
 func NewDefault() *Config {
@@ -47,25 +126,52 @@ func NewDefault() *Config {
 	}
 
 	return &Config{
-		DestDir:    defaultDest,
-		CRF:        22,
-		Preset:     "faster",
-		FPS:        30,
-		BatchStamp: true,
-		Concurrent: defaultConcurrent,
-		Notify:     true,
+		DestDir:      defaultDest,
+		CRF:          22,
+		Preset:       "faster",
+		Codec:        "libx264",
+		Resolution:   "1080p",
+		FPS:          30,
+		BatchStamp:   true,
+		Concurrent:   defaultConcurrent,
+		Notify:       true,
+		ChunkRetries: 2,
 	}
 }
 
+// StatePath returns StateDir, defaulting to $XDG_STATE_HOME/rec-watch or
+// ~/.local/state/rec-watch.
+func (c *Config) StatePath() string {
+	if c.StateDir != "" {
+		return c.StateDir
+	}
+	if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "rec-watch")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "rec-watch-state")
+	}
+	return filepath.Join(home, ".local", "state", "rec-watch")
+}
+
+// Path returns the config file location, ~/.config/rec-watch/config.yaml.
+func Path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "rec-watch", "config.yaml"), nil
+}
+
 func Load() (*Config, error) {
 	cfg := NewDefault()
 
-	home, err := os.UserHomeDir()
+	configPath, err := Path()
 	if err != nil {
 		return cfg, nil // ホームディレクトリが取れなくてもデフォルトで進む
 	}
 
-	configPath := filepath.Join(home, ".config", "rec-watch", "config.yaml")
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return cfg, nil
 	}
@@ -82,3 +188,26 @@ func Load() (*Config, error) {
 
 	return cfg, nil
 }
+
+// Diff lists the settings that differ between old and new as "key: old → new"
+// lines, keyed by their config.yaml names (used to log config reloads).
+func Diff(old, new *Config) []string {
+	var changes []string
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	t := ov.Type()
+	for i := range t.NumField() {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		a, b := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		if key == "workerToken" {
+			a, b = "***", "***"
+		}
+		changes = append(changes, fmt.Sprintf("%s: %v → %v", key, a, b))
+	}
+	return changes
+}
@@ -66,3 +66,30 @@ keywords:
 		t.Errorf("expected keywords [test], got %v", cfg.Keywords)
 	}
 }
+
+func TestDiff(t *testing.T) {
+	old := NewDefault()
+	new := NewDefault()
+	if got := Diff(old, new); len(got) != 0 {
+		t.Errorf("Diff of equal configs = %v", got)
+	}
+
+	new.CRF = 26
+	new.WatchDirs = []string{"/a", "/b"}
+	new.WorkerToken = "secret"
+	new.ProfileName = "web"
+	got := Diff(old, new)
+	want := []string{
+		"watchDirs: [] → [/a /b]",
+		"crf: 22 → 26",
+		"workerToken: *** → ***",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Diff = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Diff[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
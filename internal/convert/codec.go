@@ -0,0 +1,233 @@
+package convert
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+// codecSpec describes a supported software video encoder.
+type codecSpec struct {
+	family     string   // "h264", "hevc", "av1", "vp9"
+	maxCRF     int      // upper bound of the encoder's CRF scale
+	searchCRF  [2]int   // CRF range tried by the VMAF-targeted CRF search
+	containers []string // containers the stream can be muxed into (first = default)
+}
+
+var videoCodecs = map[string]codecSpec{
+	"libx264":    {family: "h264", maxCRF: 51, searchCRF: [2]int{14, 38}, containers: []string{"mp4", "mkv"}},
+	"libx265":    {family: "hevc", maxCRF: 51, searchCRF: [2]int{16, 40}, containers: []string{"mp4", "mkv"}},
+	"libsvtav1":  {family: "av1", maxCRF: 63, searchCRF: [2]int{20, 55}, containers: []string{"mp4", "mkv", "webm"}},
+	"libaom-av1": {family: "av1", maxCRF: 63, searchCRF: [2]int{20, 55}, containers: []string{"mp4", "mkv", "webm"}},
+	"libvpx-vp9": {family: "vp9", maxCRF: 63, searchCRF: [2]int{20, 55}, containers: []string{"webm", "mkv", "mp4"}},
+}
+
+// Friendly names accepted in config.yaml / --codec
+var codecAliases = map[string]string{
+	"h264":   "libx264",
+	"x264":   "libx264",
+	"hevc":   "libx265",
+	"h265":   "libx265",
+	"x265":   "libx265",
+	"av1":    "libsvtav1",
+	"svtav1": "libsvtav1",
+	"aom":    "libaom-av1",
+	"vp9":    "libvpx-vp9",
+}
+
+// NormalizeCodec resolves aliases ("hevc", "vp9" ...) to the ffmpeg encoder name.
+func NormalizeCodec(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "libx264"
+	}
+	if alias, ok := codecAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// videoCodec returns the configured encoder name and its spec.
+func (c *Converter) videoCodec() (string, codecSpec, error) {
+	name := NormalizeCodec(c.Cfg.Codec)
+	spec, ok := videoCodecs[name]
+	if !ok {
+		return "", codecSpec{}, fmt.Errorf("サポートされていないコーデックです: %s", c.Cfg.Codec)
+	}
+	return name, spec, nil
+}
+
+// container returns the output container (mp4/mkv/webm), defaulting per codec.
+func (c *Converter) container() (string, error) {
+	_, spec, err := c.videoCodec()
+	if err != nil {
+		return "", err
+	}
+	container := strings.ToLower(strings.TrimPrefix(c.Cfg.Container, "."))
+	if container == "" {
+		return spec.containers[0], nil
+	}
+	for _, v := range spec.containers {
+		if v == container {
+			return container, nil
+		}
+	}
+	return "", fmt.Errorf("コンテナ %s はコーデック %s に対応していません (対応: %s)", container, NormalizeCodec(c.Cfg.Codec), strings.Join(spec.containers, ", "))
+}
+
+// Validate checks codec/container, resolution, naming, verification, quality, split
+// and target size settings before any job is started.
+func (c *Converter) Validate() error {
+	if _, err := c.container(); err != nil {
+		return err
+	}
+	if _, err := parseResolution(c.Cfg.Resolution); err != nil {
+		return err
+	}
+	if err := c.validateNaming(); err != nil {
+		return err
+	}
+	if err := validateQuality(c.Cfg.Quality); err != nil {
+		return err
+	}
+	if err := c.validateSplit(); err != nil {
+		return err
+	}
+	if err := c.validateTargetVMAF(); err != nil {
+		return err
+	}
+	if c.Cfg.VerifyTolerance < 0 {
+		return fmt.Errorf("verifyTolerance は0以上を指定してください: %v", c.Cfg.VerifyTolerance)
+	}
+	return c.validateTargetSize()
+}
+
+// outputExt returns the file extension (with dot) of the configured container.
+func (c *Converter) outputExt() string {
+	container, err := c.container()
+	if err != nil {
+		return ".mp4"
+	}
+	return "." + container
+}
+
+// codecCRF maps the x264-scale CRF from config onto the encoder's own scale,
+// so that "crf: 22" gives roughly the same visual quality regardless of codec.
+// An explicit codecCrf entry in config.yaml always wins.
+func (c *Converter) codecCRF(codec string) int {
+	if v, ok := c.Cfg.CodecCRF[codec]; ok {
+		return v
+	}
+
+	spec := videoCodecs[codec]
+	crf := c.Cfg.CRF
+	switch spec.family {
+	case "hevc":
+		// x264 23 ≈ x265 28
+		crf += 5
+	case "av1":
+		// x264 23 ≈ SVT-AV1/aom 35
+		crf = crf*3/2 + 1
+	case "vp9":
+		// x264 23 ≈ VP9 33
+		crf += 10
+	}
+
+	if crf < 0 {
+		crf = 0
+	}
+	if crf > spec.maxCRF {
+		crf = spec.maxCRF
+	}
+	return crf
+}
+
+// Speed presets for encoders that do not understand x264 preset names
+var (
+	svtAV1Presets = map[string]int{"ultrafast": 12, "superfast": 11, "veryfast": 10, "faster": 9, "fast": 8, "medium": 7, "slow": 6, "slower": 5, "veryslow": 4, "placebo": 2}
+	aomCPUUsed    = map[string]int{"ultrafast": 8, "superfast": 8, "veryfast": 7, "faster": 6, "fast": 5, "medium": 4, "slow": 3, "slower": 2, "veryslow": 1, "placebo": 0}
+	vp9CPUUsed    = map[string]int{"ultrafast": 5, "superfast": 5, "veryfast": 5, "faster": 4, "fast": 3, "medium": 2, "slow": 1, "slower": 0, "veryslow": 0, "placebo": 0}
+)
+
+func presetNumber(table map[string]int, preset string, fallback int) int {
+	if v, ok := table[preset]; ok {
+		return v
+	}
+	return fallback
+}
+
+// videoCodecArgs returns the video encoder arguments (codec, quality, speed).
+// crf is on the encoder's own scale (e.g. chosen by the CRF search);
+// 0 means the CRF mapped from config.
+func (c *Converter) videoCodecArgs(crf int) ([]string, error) {
+	codec, spec, err := c.videoCodec()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Cfg.GPU {
+		// macOS VideoToolbox
+		// Apple's HW encoder uses -q:v (0-100) or -b:v. CRF doesn't work directly.
+		// Higher CRF = Lower Quality, Higher q:v = Higher Quality.
+		// Map CRF 20 -> 80, CRF 30 -> 60
+		q := 70 // default
+		if c.Cfg.CRF > 0 {
+			q = 100 - (c.Cfg.CRF * 2)
+			if q < 1 {
+				q = 1
+			}
+		}
+		switch spec.family {
+		case "h264":
+			return []string{"-c:v", "h264_videotoolbox", "-q:v", fmt.Sprintf("%d", q)}, nil
+		case "hevc":
+			return []string{"-c:v", "hevc_videotoolbox", "-q:v", fmt.Sprintf("%d", q)}, nil
+		default:
+			log.Printf("⚠️ %s はGPUエンコードに対応していないため、CPUで変換します", codec)
+		}
+	}
+
+	if crf <= 0 {
+		crf = c.codecCRF(codec)
+	}
+	crfArg := fmt.Sprintf("%d", crf)
+	switch codec {
+	case "libsvtav1":
+		return []string{"-c:v", codec, "-preset", fmt.Sprintf("%d", presetNumber(svtAV1Presets, c.Cfg.Preset, 8)), "-crf", crfArg}, nil
+	case "libaom-av1":
+		return []string{"-c:v", codec, "-cpu-used", fmt.Sprintf("%d", presetNumber(aomCPUUsed, c.Cfg.Preset, 6)), "-row-mt", "1", "-crf", crfArg, "-b:v", "0"}, nil
+	case "libvpx-vp9":
+		return []string{"-c:v", codec, "-deadline", "good", "-cpu-used", fmt.Sprintf("%d", presetNumber(vp9CPUUsed, c.Cfg.Preset, 4)), "-row-mt", "1", "-crf", crfArg, "-b:v", "0"}, nil
+	default:
+		// libx264 / libx265 share preset names and -crf
+		return []string{"-vcodec", codec, "-preset", c.Cfg.Preset, "-crf", crfArg}, nil
+	}
+}
+
+// containerArgs returns muxer options for the configured container.
+func (c *Converter) containerArgs() []string {
+	container, err := c.container()
+	if err != nil || container != "mp4" {
+		return nil
+	}
+	args := []string{"-movflags", "+faststart"}
+	if _, spec, err := c.videoCodec(); err == nil && spec.family == "hevc" {
+		// QuickTime / Finder preview only play HEVC tagged as hvc1
+		args = append(args, "-tag:v", "hvc1")
+	}
+	return args
+}
+
+// audioArgs returns the audio encoder arguments paired with the container
+// (Opus for webm, AAC otherwise).
+func (c *Converter) audioArgs(mediaInfo *probe.MediaInfo) []string {
+	if c.Cfg.Mute || (mediaInfo != nil && !mediaInfo.HasAudio()) {
+		return []string{"-an"}
+	}
+	if container, _ := c.container(); container == "webm" {
+		return []string{"-acodec", "libopus", "-b:a", "128k", "-ac", "2"}
+	}
+	return []string{"-acodec", "aac", "-b:a", "128k", "-ac", "2"}
+}
@@ -0,0 +1,71 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/mt4110/rec-watch/internal/config"
+)
+
+func TestCodecCRF(t *testing.T) {
+	tests := []struct {
+		codec string
+		crf   int
+		want  int
+	}{
+		{"libx264", 22, 22},
+		{"libx265", 23, 28},
+		{"libsvtav1", 23, 35},
+		{"libvpx-vp9", 23, 33},
+		{"libvpx-vp9", 60, 63}, // clamped to the encoder's max
+	}
+
+	for _, tt := range tests {
+		c := New(&config.Config{CRF: tt.crf, Codec: tt.codec})
+		if got := c.codecCRF(tt.codec); got != tt.want {
+			t.Errorf("codecCRF(%s, %d) = %d, want %d", tt.codec, tt.crf, got, tt.want)
+		}
+	}
+
+	c := New(&config.Config{CRF: 22, CodecCRF: map[string]int{"libx265": 26}})
+	if got := c.codecCRF("libx265"); got != 26 {
+		t.Errorf("expected codecCrf override 26, got %d", got)
+	}
+}
+
+func TestContainer(t *testing.T) {
+	tests := []struct {
+		name      string
+		codec     string
+		container string
+		want      string
+		wantErr   bool
+	}{
+		{"default h264", "", "", "mp4", false},
+		{"vp9 defaults to webm", "vp9", "", "webm", false},
+		{"hevc in mkv", "hevc", "mkv", "mkv", false},
+		{"av1 in webm", "libsvtav1", ".webm", "webm", false},
+		{"h264 in webm is invalid", "libx264", "webm", "", true},
+		{"unknown codec", "mpeg2video", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&config.Config{Codec: tt.codec, Container: tt.container})
+			got, err := c.container()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("container() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("container() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioArgs_OpusForWebm(t *testing.T) {
+	c := New(&config.Config{Codec: "vp9"})
+	args := c.audioArgs(nil)
+	if len(args) < 2 || args[1] != "libopus" {
+		t.Errorf("expected libopus for webm, got %v", args)
+	}
+}
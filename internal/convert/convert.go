@@ -1,9 +1,12 @@
 package convert
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/url"
 	"os"
 	"os/exec"
@@ -13,6 +16,8 @@ import (
 	"time"
 
 	"github.com/mt4110/rec-watch/internal/config"
+	"github.com/mt4110/rec-watch/internal/probe"
+	"github.com/mt4110/rec-watch/internal/remote"
 	"github.com/mt4110/rec-watch/internal/split"
 )
 
@@ -33,14 +38,73 @@ func SendNotification(title, message, filePath string) {
 }
 
 type Converter struct {
-	Cfg *config.Config
+	Cfg    *config.Config
+	Prober *probe.Prober
+	// Remote sends split chunks to the configured workers
+	Remote *remote.Client
+	// Sched limits the ffmpeg processes of all jobs (see Scheduler)
+	Sched *Scheduler
+	// OnProgress is called with live ffmpeg progress (optional, e.g. watcher/TUI)
+	OnProgress ProgressFunc
+
+	reserved *reservations // output paths of running jobs (see reserveOutput)
+}
+
+type reservations struct {
+	mu    sync.Mutex
+	paths map[string]bool
 }
 
 func New(cfg *config.Config) *Converter {
-	return &Converter{Cfg: cfg}
+	ffprobeBin := cfg.FFprobeBin
+	if ffprobeBin == "" {
+		ffprobeBin = probe.BinFromFFmpeg(cfg.FFmpegBin)
+	}
+	return &Converter{
+		Cfg:      cfg,
+		Prober:   probe.New(ffprobeBin),
+		Remote:   remote.NewClient(cfg.WorkerToken),
+		Sched:    NewScheduler(cfg.Concurrent, cfg.CPUThreads),
+		reserved: &reservations{paths: map[string]bool{}},
+	}
+}
+
+// WithConfig validates a reloaded config and returns a converter for it. Jobs
+// already running keep using c; both share the scheduler (resized to the new
+// concurrent and cpuThreads), the output name reservations and OnProgress.
+func (c *Converter) WithConfig(cfg *config.Config) (*Converter, error) {
+	next := New(cfg)
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	c.Sched.Resize(cfg.Concurrent, cfg.CPUThreads)
+	next.Sched = c.Sched
+	next.reserved = c.reserved
+	next.OnProgress = c.OnProgress
+	return next, nil
 }
 
-func (c *Converter) ProcessFiles(files []string) {
+// probeInput runs ffprobe on the input and rejects files that cannot be converted
+// (e.g. audio-only files) before ffmpeg is started.
+// In DryRun mode a probe failure is only logged so that the command can still be shown.
+func (c *Converter) probeInput(ctx context.Context, inPath string) (*probe.MediaInfo, error) {
+	mediaInfo, err := c.Prober.Probe(ctx, inPath)
+	if err != nil {
+		if c.Cfg.DryRun {
+			log.Printf("[DryRun] ffprobe失敗 (続行します): %v", err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !mediaInfo.HasVideo() {
+		return nil, fmt.Errorf("映像ストリームがありません: %s", inPath)
+	}
+	return mediaInfo, nil
+}
+
+// ProcessFiles converts files in parallel. When ctx is cancelled (Ctrl+C) running
+// ffmpeg processes are killed, no new jobs are started and ctx.Err() is returned.
+func (c *Converter) ProcessFiles(ctx context.Context, files []string) error {
 	// 出力ディレクトリを作成
 	baseOut, _ := filepath.Abs(c.Cfg.DestDir)
 	batchDir := baseOut
@@ -48,189 +112,198 @@ func (c *Converter) ProcessFiles(files []string) {
 		batchDir = filepath.Join(baseOut, nowStamp())
 	}
 	if err := os.MkdirAll(batchDir, 0755); err != nil {
-		log.Fatalf("出力ディレクトリの作成に失敗: %v", err)
+		return fmt.Errorf("出力ディレクトリの作成に失敗: %w", err)
 	}
 
 	log.Printf("変換対象: %d件", len(files))
 	log.Printf("出力先: %s", batchDir)
-	log.Printf("並列実行数: %d", c.Cfg.Concurrent)
+	log.Printf("並列実行数: %d", c.Sched.Slots())
 
+	// The semaphore only limits files in flight; their ffmpeg processes (including
+	// split chunks) share the slots and CPU threads of c.Sched.
 	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, c.Cfg.Concurrent)
+	semaphore := make(chan struct{}, c.Sched.Slots())
 
+loop:
 	for _, inPath := range files {
+		// 実行枠を確保 (キャンセルされたら新しいジョブは開始しない)
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
 		wg.Add(1)
-		semaphore <- struct{}{} // 実行枠を確保
 
 		go func(inPath string) {
 			defer func() {
 				<-semaphore // 実行枠を解放
 				wg.Done()
 			}()
-			if _, err := c.Convert(inPath, batchDir); err != nil {
+			if _, err := c.Convert(ctx, inPath, batchDir); err != nil {
+				if ctx.Err() != nil {
+					log.Printf("🛑 変換を中断しました: %s", inPath)
+					return
+				}
+				if errors.Is(err, ErrOutputExists) {
+					log.Printf("⏭ %v", err)
+					return
+				}
 				log.Printf("❌ 変換失敗: %s -> %v", inPath, err)
 			}
 		}(inPath)
 	}
 
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		log.Println("🛑 中断しました")
+		return err
+	}
 	log.Println("✅ すべて完了")
+	return nil
 }
 
-func (c *Converter) Convert(inPath string, outDir string) (string, error) {
-	// Check for Parallel Split Mode
-	// Threshold: e.g. 1GB (1024*1024*1024 bytes)
-	// For testing, let's say 500MB or if requested via config
+func (c *Converter) Convert(ctx context.Context, inPath string, outDir string) (string, error) {
+	// Probe once per input; ConvertOne/ConvertSplit reuse the result
+	mediaInfo, err := c.probeInput(ctx, inPath)
+	if err != nil {
+		return "", err
+	}
 
-	shouldSplit := c.Cfg.ParallelSplit
+	// Size-capped two-pass encode (never split or stream-copied)
+	if c.Cfg.TargetSize != "" {
+		return c.convertTargetSize(ctx, inPath, outDir, mediaInfo)
+	}
 
-	// If auto-detect logic is needed:
-	// info, _ := os.Stat(inPath)
-	// if info.Size() > 1*1024*1024*1024 { shouldSplit = true }
+	// Parallel split mode: forced by --parallel-split or above autoSplitDuration / autoSplitSize
+	shouldSplit := c.Cfg.ParallelSplit || c.autoSplit(inPath, mediaInfo)
 
-	if shouldSplit {
-		// GPU mode overrides Split (checked inside ConvertSplit or here)
-		if c.Cfg.GPU {
-			// GPU mode doesn't need split usually, but user asked for comparison.
-			// Using GPU on split chunks is possible but maybe inefficient startup overhead.
-			// Let's stick to: if GPU, linear GPU. If CPU, maybe Split.
-			// But for now, let's implement Split Logic here.
-			// Actually, let's keep it simple: ConvertSplit calls ConvertOne for chunks.
-			return c.ConvertSplit(inPath, outDir)
+	// Stream copy is faster than any split encode
+	if shouldSplit && c.planStreams(mediaInfo).copyVideo {
+		shouldSplit = false
+	}
+
+	// Quality-targeted mode: pick the CRF on samples before the full encode
+	var search *crfSearchResult
+	if c.Cfg.TargetVMAF > 0 && !c.planStreams(mediaInfo).copyVideo {
+		search, err = c.searchCRF(ctx, inPath, mediaInfo)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", err
+			}
+			log.Printf("⚠️ CRF探索に失敗しました (設定のCRFで変換します): %v", err)
 		}
-		return c.ConvertSplit(inPath, outDir)
 	}
 
-	return c.ConvertOne(inPath, outDir)
-}
+	if shouldSplit {
+		return c.convertSplit(ctx, inPath, outDir, mediaInfo, search)
+	}
 
-func (c *Converter) ConvertOne(inPath string, outDir string) (string, error) {
+	return c.convertOne(ctx, inPath, outDir, mediaInfo, search)
+}
 
-	// ファイルの更新日時を取得してファイル名にする
-	info, err := os.Stat(inPath)
-	var timeStamp string
+func (c *Converter) ConvertOne(ctx context.Context, inPath string, outDir string) (string, error) {
+	mediaInfo, err := c.probeInput(ctx, inPath)
 	if err != nil {
-		timeStamp = time.Now().Format("2006-01-02_15-04-05")
-	} else {
-		timeStamp = info.ModTime().Format("2006-01-02_15-04-05")
+		return "", err
 	}
+	return c.convertOne(ctx, inPath, outDir, mediaInfo, nil)
+}
 
-	outPath := filepath.Join(outDir, fmt.Sprintf("%s.mp4", timeStamp))
-
-	vf := "scale=1920:1080:force_original_aspect_ratio=decrease"
-	if !c.Cfg.NoPad {
-		vf += ",pad=1920:1080:(ow-iw)/2:(oh-ih)/2"
+// search is the result of the CRF search (nil = CRF from config).
+func (c *Converter) convertOne(ctx context.Context, inPath string, outDir string, mediaInfo *probe.MediaInfo, search *crfSearchResult) (string, error) {
+	// ファイルの更新日時などからファイル名を決める (nameTemplate)
+	outPath, release, err := c.reserveOutput(inPath, outDir)
+	if err != nil {
+		return "", err
 	}
+	defer release()
 
-	ffmpegPath := "ffmpeg"
-	if c.Cfg.FFmpegBin != "" {
-		ffmpegPath = c.Cfg.FFmpegBin
-	}
+	// Remux / stream copy if the input already meets the target
+	plan := c.planStreams(mediaInfo)
 
 	ffmpegArgs := []string{
 		"-i", inPath,
 	}
 
+	// Filter chain for the quality reference (same scaling/fps as the output)
+	refFilter := ""
+
 	// Codec Selection
-	if c.Cfg.GPU {
-		// macOS VideoToolbox
-		ffmpegArgs = append(ffmpegArgs, "-c:v", "h264_videotoolbox")
-		// Bitrate or Quality control for GPU
-		// Apple's HW encoder uses -q:v (0-100) or -b:v.
-		// CRF doesn't work directly.
-		// Mapping simplistic CRF to Quality is hard.
-		// Let's use a quality setting or default.
-		// q:v 60-80 is usually good.
-		// Let's assume some default if not specified/mapped.
-		// For simplicity, we just use default or mapped CRF inverse?
-		// Higher CRF = Lower Quality.
-		// Higher q:v = Higher Quality.
-		// q = 100 - CRF*2 ? (Roughly)
-		q := 70 // default
-		if c.Cfg.CRF > 0 {
-			// Map CRF 20 -> 80, CRF 30 -> 60
-			q = 100 - (c.Cfg.CRF * 2)
-			if q < 1 {
-				q = 1
-			}
-		}
-		ffmpegArgs = append(ffmpegArgs, "-q:v", fmt.Sprintf("%d", q))
+	if plan.copyVideo {
+		ffmpegArgs = append(ffmpegArgs, "-c:v", "copy")
 	} else {
-		// CPU x264
-		ffmpegArgs = append(ffmpegArgs, "-vcodec", "libx264")
-		ffmpegArgs = append(ffmpegArgs, "-preset", c.Cfg.Preset)
-		ffmpegArgs = append(ffmpegArgs, "-crf", fmt.Sprintf("%d", c.Cfg.CRF))
-	}
+		codecArgs, err := c.videoCodecArgs(search.chosenCRF())
+		if err != nil {
+			return "", err
+		}
+		ffmpegArgs = append(ffmpegArgs, codecArgs...)
 
-	ffmpegArgs = append(ffmpegArgs,
-		"-vf", vf,
-		"-movflags", "+faststart",
-	)
+		vf, err := c.videoFilter(mediaInfo)
+		if err != nil {
+			return "", err
+		}
+		if vf != "" {
+			ffmpegArgs = append(ffmpegArgs, "-vf", vf)
+		}
 
-	if c.Cfg.FPS > 0 {
-		ffmpegArgs = append(ffmpegArgs, "-r", fmt.Sprintf("%d", c.Cfg.FPS))
+		if c.Cfg.FPS > 0 {
+			ffmpegArgs = append(ffmpegArgs, "-r", fmt.Sprintf("%d", c.Cfg.FPS))
+		}
+		refFilter = qualityRefFilter(vf, c.Cfg.FPS)
 	}
+	ffmpegArgs = append(ffmpegArgs, c.containerArgs()...)
 
-	if c.Cfg.Mute {
-		ffmpegArgs = append(ffmpegArgs, "-an")
+	if plan.copyAudio {
+		ffmpegArgs = append(ffmpegArgs, "-c:a", "copy")
 	} else {
-		ffmpegArgs = append(ffmpegArgs, "-acodec", "aac", "-b:a", "128k", "-ac", "2")
+		ffmpegArgs = append(ffmpegArgs, c.audioArgs(mediaInfo)...)
 	}
 
-	ffmpegArgs = append(ffmpegArgs, outPath)
+	// Encode into a hidden temp file; the final name appears only once ffmpeg succeeded
+	tmpPath := tempOutputPath(outPath)
+	ffmpegArgs = append(ffmpegArgs, "-y", tmpPath)
 
-	log.Printf("▶ 変換: %s -> %s", inPath, outPath)
+	log.Printf("▶ 変換 (%s): %s -> %s", plan.mode(), inPath, outPath)
 	startTime := time.Now()
 
 	if c.Cfg.DryRun {
-		// cmdLen := fmt.Sprintf("%s %s", ffmpegPath, fmt.Sprint(ffmpegArgs))
-		log.Printf("[DryRun] Command: %s %v", ffmpegPath, ffmpegArgs)
+		log.Printf("[DryRun] Command: %s %v", c.ffmpegPath(), ffmpegArgs)
 		return outPath, nil // Return success for dry-run
 	}
 
-	cmd := exec.Command(ffmpegPath, ffmpegArgs...)
+	progress := c.newProgress(inPath, mediaInfo)
+	if err := c.runFFmpeg(ctx, ffmpegArgs, progress.part(0)); err != nil {
+		// Never leave a half-written file in the dest dir
+		os.Remove(tmpPath)
+		return "", err
+	}
+	// The source is only trashed after the output passed verification
+	if err := c.verifyOutput(ctx, tmpPath, mediaInfo); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := commitOutput(tmpPath, outPath); err != nil {
+		return "", err
+	}
+	progress.finish()
+	elapsed := time.Since(startTime)
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("ffmpeg実行エラー: %v\n%s", err, string(output))
-	}
-
-	// Stats collecting
-	duration := time.Since(startTime).Seconds()
-	infoOut, _ := os.Stat(outPath)
-	convertedSize := infoOut.Size()
-	originalSize := info.Size()
-
-	logEntry := struct {
-		Type          string  `json:"type"`
-		Input         string  `json:"input"`
-		Output        string  `json:"output"`
-		DurationSec   float64 `json:"duration_sec"`
-		OriginalSize  int64   `json:"original_size"`
-		ConvertedSize int64   `json:"converted_size"`
-		SizeDiff      int64   `json:"size_diff"`
-		Timestamp     string  `json:"timestamp"`
-	}{
-		Type:          "conversion_result",
-		Input:         inPath,
-		Output:        outPath,
-		DurationSec:   duration,
-		OriginalSize:  originalSize,
-		ConvertedSize: convertedSize,
-		SizeDiff:      originalSize - convertedSize,
-		Timestamp:     time.Now().Format(time.RFC3339),
-	}
-
-	if jsonBytes, err := json.Marshal(logEntry); err == nil {
-		// Logger writes to file, we use a special prefix or just raw JSON line
-		// Since we use std log which adds date/time prefix, it might break pure JSON lines if we are not careful.
-		// However, for simplicity, we'll just log the JSON string. The stats command will have to handle the log prefix.
-		// Or we can assume the stats command filters lines that look like JSON.
-		log.Println(string(jsonBytes))
+	// A copied video stream is identical to the source, nothing to measure
+	var quality *QualityScores
+	if !plan.copyVideo {
+		quality = c.qualityReport(ctx, inPath, outPath, refFilter, mediaInfo)
 	}
 
-	if !c.Cfg.NoTrash && !c.Cfg.DryRun {
+	c.logResult(resultRecord{
+		Input:       inPath,
+		Output:      outPath,
+		Mode:        plan.mode(),
+		DurationSec: elapsed.Seconds(),
+		Quality:     quality,
+		CRFSearch:   search,
+	}, mediaInfo)
 
+	if !c.Cfg.NoTrash && !c.Cfg.DryRun {
 		if err := moveToTrash(inPath); err != nil {
 			log.Printf("🗑 ゴミ箱への移動に失敗: %s -> %v", inPath, err)
 		}
@@ -239,6 +312,58 @@ func (c *Converter) ConvertOne(inPath string, outDir string) (string, error) {
 	return outPath, nil
 }
 
+// resultRecord is the "conversion_result" JSON line that `rec-watch stats` aggregates.
+type resultRecord struct {
+	Type             string  `json:"type"`
+	Input            string  `json:"input"`
+	Output           string  `json:"output"`
+	Mode             string  `json:"mode"` // encode, remux, copy_audio, copy_video, split, target_size
+	DurationSec      float64 `json:"duration_sec"`
+	MediaDurationSec float64 `json:"media_duration_sec,omitempty"`
+	SourceCodec      string  `json:"source_codec,omitempty"`
+	SourceWidth      int     `json:"source_width,omitempty"`
+	SourceHeight     int     `json:"source_height,omitempty"`
+	OriginalSize     int64   `json:"original_size"`
+	ConvertedSize    int64   `json:"converted_size"`
+	SizeDiff         int64   `json:"size_diff"`
+	Timestamp        string  `json:"timestamp"`
+	// Quality is set when quality measurement is enabled (config "quality")
+	Quality *QualityScores `json:"quality,omitempty"`
+	// CRFSearch is set when the CRF was chosen for targetVmaf
+	CRFSearch *crfSearchResult `json:"crf_search,omitempty"`
+}
+
+// logResult completes rec (sizes, source info, timestamp) and writes it as the
+// conversion_result line. rec.DurationSec is the conversion time without quality measurement.
+func (c *Converter) logResult(rec resultRecord, mediaInfo *probe.MediaInfo) {
+	if info, err := os.Stat(rec.Input); err == nil {
+		rec.OriginalSize = info.Size()
+	}
+	if info, err := os.Stat(rec.Output); err == nil {
+		rec.ConvertedSize = info.Size()
+	}
+
+	rec.Type = "conversion_result"
+	rec.SizeDiff = rec.OriginalSize - rec.ConvertedSize
+	rec.Timestamp = time.Now().Format(time.RFC3339)
+	if mediaInfo != nil {
+		rec.MediaDurationSec = mediaInfo.Duration
+		if mediaInfo.Video != nil {
+			rec.SourceCodec = mediaInfo.Video.Codec
+			rec.SourceWidth = mediaInfo.Video.Width
+			rec.SourceHeight = mediaInfo.Video.Height
+		}
+	}
+
+	if jsonBytes, err := json.Marshal(rec); err == nil {
+		// Logger writes to file, we use a special prefix or just raw JSON line
+		// Since we use std log which adds date/time prefix, it might break pure JSON lines if we are not careful.
+		// However, for simplicity, we'll just log the JSON string. The stats command will have to handle the log prefix.
+		// Or we can assume the stats command filters lines that look like JSON.
+		log.Println(string(jsonBytes))
+	}
+}
+
 func moveToTrash(path string) error {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
@@ -268,172 +393,213 @@ func nowStamp() string {
 	return time.Now().Format("20060102")
 }
 
-func (c *Converter) ConvertSplit(inPath string, outDir string) (string, error) {
-	log.Printf("🚀 並列分割モードで処理開始: %s", filepath.Base(inPath))
+func (c *Converter) ConvertSplit(ctx context.Context, inPath string, outDir string) (string, error) {
+	mediaInfo, err := c.probeInput(ctx, inPath)
+	if err != nil {
+		return "", err
+	}
+	return c.convertSplit(ctx, inPath, outDir, mediaInfo, nil)
+}
 
-	if c.Cfg.DryRun {
-		log.Printf("[DryRun] Would split %s into chunks...", inPath)
+func (c *Converter) convertSplit(ctx context.Context, inPath string, outDir string, mediaInfo *probe.MediaInfo, search *crfSearchResult) (string, error) {
+	// Splitting a clip shorter than 1.5 segments only adds overhead
+	segment := c.splitSegment()
+	if mediaInfo != nil && mediaInfo.Duration > 0 && mediaInfo.Duration < float64(segment)*3/2 {
+		log.Printf("ℹ️ 動画が短いため分割せずに変換します (%.0f秒): %s", mediaInfo.Duration, filepath.Base(inPath))
+		return c.convertOne(ctx, inPath, outDir, mediaInfo, search)
 	}
 
-	// Temp Dir
-	tmpDir, err := os.MkdirTemp("", "rec-watch-split-*")
+	// Final Output Path (using same logic as ConvertOne for naming)
+	finalOutPath, release, err := c.reserveOutput(inPath, outDir)
 	if err != nil {
 		return "", err
 	}
-	defer os.RemoveAll(tmpDir)
+	defer release()
+
+	log.Printf("🚀 並列分割モードで処理開始: %s", filepath.Base(inPath))
+	startTime := time.Now()
 
 	// 1. Split
-	// Split into e.g. 5 minutes (300s) chunks? Or shorter for more parallelism?
-	// 5 mins is good balance.
+	// Balanced chunks cut at keyframes (splitSegmentSec each, 5 minutes by default).
+	// Fixed-length segments are the fallback when keyframes cannot be probed.
+	// Chunks live in a per-job work dir with a manifest, so that a failed or
+	// interrupted job continues from the chunks already encoded.
 	s := split.New(c.Cfg.FFmpegBin)
-	var chunks []string
+	crf := search.chosenCRF()
 	if c.Cfg.DryRun {
-		// Mock chunks
-		chunks = []string{
-			filepath.Join(tmpDir, "chunk_000.mp4"),
-			filepath.Join(tmpDir, "chunk_001.mp4"),
-			filepath.Join(tmpDir, "chunk_002.mp4"),
+		return c.dryRunSplit(ctx, inPath, finalOutPath, mediaInfo, crf)
+	}
+	job, err := c.openSplitJob(inPath, crf)
+	if err != nil {
+		return "", err
+	}
+	if job.resumed() {
+		done := 0
+		for _, ch := range job.chunks() {
+			if ch.Done {
+				done++
+			}
 		}
+		log.Printf("♻️ 前回の続きから再開します (完了済み %d/%d チャンク): %s", done, len(job.chunks()), job.dir)
 	} else {
-		chunks, err = s.Split(inPath, tmpDir, 300)
+		cuts, err := c.splitCuts(ctx, inPath, mediaInfo)
 		if err != nil {
 			return "", err
 		}
+		sourceDir := filepath.Join(job.dir, "source")
+		var chunks []string
+		if len(cuts) > 0 {
+			chunks, err = s.SplitAt(ctx, inPath, sourceDir, cuts)
+		} else {
+			chunks, err = s.Split(ctx, inPath, sourceDir, segment)
+		}
+		if err != nil {
+			job.remove()
+			return "", err
+		}
+		if err := job.setChunks(chunks, c.outputExt()); err != nil {
+			job.remove()
+			return "", err
+		}
 	}
 
 	// 2. Parallel Transcode chunks
-	// We want to reuse ConvertOne logic but output to tmpDir
-	// We need 'wait group' again here, basically sub-scheduling.
-	// NOTE: This will use available CPU. Since we are inside a worker, this might spawn more threads.
-	// If global concurrency is limited, we might be blocked if we use the same semaphore?
-	// But ConvertOne doesn't use semaphore, ProcessFiles does.
-	// So we can spawn goroutines here freely, but we should be careful about exploding CPU usage.
-	// FFmpeg x264 already uses multi-threads.
-	// Running multiple FFmpegs on multicores is beneficial but too many is bad.
-	// For "Split" mode, we assume this file takes over the machine.
-
-	type result struct {
-		index int
-		path  string
-		err   error
-	}
-
-	results := make([]result, len(chunks))
+	// Chunks are spread over the local slots (Concurrent, or 4) and the slots of
+	// the configured workers; see slotPool. FFmpeg x264 already uses multi-threads,
+	// so for "Split" mode we assume this file takes over the machine.
+	chunks := job.chunks()
+	errs := make([]error, len(chunks))
 	var wg sync.WaitGroup
-	// Limit sub-parallelism to e.g. 4 or Config.Concurrent
-	// If main loop has concurrency, this might be recursive.
-	// For v0.5.0, we assume --parallel-split is used with --concurrent=1 for the main loop effectively,
-	// OR we manage it.
 
-	sem := make(chan struct{}, c.Cfg.Concurrent) // Reuse configured concurrency or e.g. 4
-	if c.Cfg.Concurrent == 0 {
-		sem = make(chan struct{}, 4)
+	pool := c.newSlotPool(ctx)
+	log.Printf("⚡️ %d個のチャンクを %d並列で変換中...", len(chunks), pool.size())
+	progress := c.newProgress(inPath, mediaInfo)
+
+	// A chunk that fails even after its retries fails the whole file, so stop the others early
+	chunkCtx, cancelChunks := context.WithCancel(ctx)
+	defer cancelChunks()
+
+	// The audio track is encoded once for the whole input, in parallel with the video
+	// chunks. Encoding it per chunk gives clicks and A/V drift at every chunk boundary
+	// (AAC encoder priming), so the chunks are video only and audio is muxed at merge.
+	wantAudio := !c.Cfg.Mute && mediaInfo.HasAudio()
+	var audioErr error
+	audioFinished := make(chan struct{})
+	if wantAudio && !job.audioDone() {
+		go func() {
+			defer close(audioFinished)
+			audioErr = c.encodeAudioTrack(chunkCtx, inPath, job, mediaInfo)
+			if audioErr != nil && chunkCtx.Err() == nil {
+				log.Printf("⚠️ 音声の変換に失敗: %s: %v", inPath, audioErr)
+				cancelChunks()
+			}
+		}()
+	} else {
+		close(audioFinished)
 	}
 
-	log.Printf("⚡️ %d個のチャンクを %d並列で変換中...", len(chunks), cap(sem))
-
-	for i, chunk := range chunks {
+	for _, ch := range chunks {
+		if ch.Done {
+			continue
+		}
+		slot, err := pool.acquire(chunkCtx)
+		if err != nil {
+			errs[ch.Index] = err
+			continue
+		}
 		wg.Add(1)
-		sem <- struct{}{}
-		go func(i int, chunkPath string) {
-			defer func() {
-				<-sem
-				wg.Done()
-			}()
-
-			// Output name in temp dir
-			// ConvertOne adds timestamp to name, we need to preserve order.
-			// We can hack ConvertOne or just craft args manually...
-			// ConvertOne is designed for final output.
-			// Let's just create a helper or use ConvertOne but rename result?
-			// ConvertOne uses logic to ensure unique name in outDir.
-			// If we pass tmpDir as outDir, it will work.
-
-			// ACTUALLY: ConvertOne applies scaling/padding/crf.
-			// We want exactly that.
-			// But we need to keep filenames sortable or track them.
-			// ConvertOne returns outPath.
-
-			chuckOutDir := filepath.Join(tmpDir, "converted")
-			os.MkdirAll(chuckOutDir, 0755)
-
-			// We forcibly use the same basename to keep 'chunk_001' part for sorting?
-			// But ConvertOne changes name to timestamp... that breaks sorting order!
-			// ConvertOne: `outPath := filepath.Join(outDir, fmt.Sprintf("%s.mp4", timeStamp))`
-			// This is BAD for Split mode. We need filename preservation.
-
-			// Fix: We need a lower level function `doConvert(in, out)` used by ConvertOne.
-			// Refactoring ConvertOne slightly.
-
-			outFile := filepath.Join(chuckOutDir, filepath.Base(chunkPath)) // chunk_000.mp4
-
-			// Use internal private method if we refactor, or just Copy/Paste logic for V1?
-			// Let's refactor ConvertOne to use `convertFile(in, out)`
-			err := c.convertFile(chunkPath, outFile)
-			results[i] = result{index: i, path: outFile, err: err}
-			if err != nil {
-				log.Printf("⚠️ チャンク変換失敗: %s: %v", chunkPath, err)
+		go func(ch chunkState) {
+			defer wg.Done()
+
+			// Converted chunks keep the chunk_NNN name so that they stay in order.
+			// The slot may change when a worker is lost (see dispatchChunk).
+			err := c.encodeChunk(chunkCtx, job, ch, func(ctx context.Context, in, out string) error {
+				var err error
+				slot, err = c.dispatchChunk(ctx, pool, slot, ch.Index, in, out, mediaInfo, crf, progress.part(ch.Index))
+				return err
+			})
+			pool.release(slot)
+			progress.endPart(ch.Index)
+			errs[ch.Index] = err
+			if err != nil && chunkCtx.Err() == nil {
+				log.Printf("⚠️ チャンク変換失敗: %s: %v", ch.Source, err)
+				cancelChunks()
 			}
-		}(i, chunk)
+		}(ch)
 	}
 	wg.Wait()
+	<-audioFinished
+
+	if err := ctx.Err(); err != nil {
+		log.Printf("⏸ 分割ジョブを中断しました。次回は続きから再開します: %s", job.dir)
+		return "", err
+	}
 
-	// Check errors
-	var convertedChunks []string
-	for _, res := range results {
-		if res.err != nil {
-			return "", fmt.Errorf("chunk %d failed: %v", res.index, res.err)
+	// Check errors (the work dir is kept so that a rerun only redoes the failed chunks)
+	for i, err := range errs {
+		if err != nil {
+			if audioErr != nil && errors.Is(err, context.Canceled) {
+				// Stopped because the audio failed
+				break
+			}
+			return "", fmt.Errorf("chunk %d failed: %v (再実行すると続きから再開します: %s)", i, err, job.dir)
 		}
-		convertedChunks = append(convertedChunks, res.path)
+	}
+	if audioErr != nil {
+		return "", fmt.Errorf("audio failed: %v (再実行すると続きから再開します: %s)", audioErr, job.dir)
 	}
 
 	// 3. Merge (Concat)
 	// Create concat list
-	listFile := filepath.Join(tmpDir, "concat.txt")
+	listFile := filepath.Join(job.dir, "concat.txt")
 	f, err := os.Create(listFile)
 	if err != nil {
 		return "", err
 	}
 
-	for _, chunk := range convertedChunks {
+	for _, ch := range chunks {
 		// path should be absolute or relative. Absolute is safest.
-		abs, _ := filepath.Abs(chunk)
+		abs, _ := filepath.Abs(ch.Output)
 		f.WriteString(fmt.Sprintf("file '%s'\n", abs))
 	}
 	f.Close()
 
-	// Final Output Path (using same logic as ConvertOne for naming)
-	// We need to determine final output name.
-	info, _ := os.Stat(inPath)
-	timeStamp := info.ModTime().Format("2006-01-02_15-04-05")
-	finalOutPath := filepath.Join(outDir, fmt.Sprintf("%s.mp4", timeStamp))
-
 	log.Println("🔗 チャンクを結合中...")
 
-	// ffmpeg -f concat -safe 0 -i list.txt -c copy out.mp4
-	mergeArgs := []string{
-		"-f", "concat",
-		"-safe", "0",
-		"-i", listFile,
-		"-c", "copy",
-		finalOutPath,
+	tmpOutPath := tempOutputPath(finalOutPath)
+	if err := c.runFFmpeg(ctx, c.mergeArgs(listFile, job, wantAudio, tmpOutPath), nil); err != nil {
+		os.Remove(tmpOutPath)
+		return "", fmt.Errorf("merge failed: %v", err)
 	}
-
-	cmd := exec.Command(c.Cfg.FFmpegBin, mergeArgs...)
-	if c.Cfg.FFmpegBin == "" {
-		cmd = exec.Command("ffmpeg", mergeArgs...)
+	if err := c.verifyOutput(ctx, tmpOutPath, mediaInfo); err != nil {
+		os.Remove(tmpOutPath)
+		if ctx.Err() == nil {
+			// The chunks themselves are suspect, do not resume from them
+			job.remove()
+		}
+		return "", err
 	}
-
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return "", fmt.Errorf("merge failed: %v\n%s", err, string(out))
+	if err := commitOutput(tmpOutPath, finalOutPath); err != nil {
+		return "", err
 	}
+	job.remove()
+	progress.finish()
+
+	elapsed := time.Since(startTime)
 
-	// 4. Logging & Trash (Standard process) - Handled by caller 'ProcessFiles' if we returned simple error?
-	// Wait, ConvertOne handled Trash. ConvertSplit should too.
-	// Duplicated logic from ConvertOne end.
+	var quality *QualityScores
+	if vf, err := c.videoFilter(mediaInfo); err == nil {
+		quality = c.qualityReport(ctx, inPath, finalOutPath, qualityRefFilter(vf, c.Cfg.FPS), mediaInfo)
+	}
 
-	// Stats collecting (manually for now or reuse)
-	// ...
+	c.logResult(resultRecord{
+		Input:       inPath,
+		Output:      finalOutPath,
+		Mode:        "split",
+		DurationSec: elapsed.Seconds(),
+		Quality:     quality,
+		CRFSearch:   search,
+	}, mediaInfo)
 
 	// Trash
 	if !c.Cfg.NoTrash && !c.Cfg.DryRun {
@@ -445,61 +611,121 @@ func (c *Converter) ConvertSplit(inPath string, outDir string) (string, error) {
 	return finalOutPath, nil
 }
 
+// mergeArgs joins the converted chunks listed in listFile (and the audio track
+// of job) into outPath without re-encoding:
+// ffmpeg -f concat -safe 0 -i list.txt [-i audio.mka -map 0:v:0 -map 1:a:0] -c copy out.mp4
+func (c *Converter) mergeArgs(listFile string, job *splitJob, wantAudio bool, outPath string) []string {
+	args := []string{
+		"-f", "concat",
+		"-safe", "0",
+		"-i", listFile,
+	}
+	if wantAudio {
+		args = append(args, "-i", job.audioPath(), "-map", "0:v:0", "-map", "1:a:0")
+	}
+	args = append(args, "-c", "copy")
+	args = append(args, c.containerArgs()...)
+	return append(args, "-y", outPath)
+}
+
+// dryRunSplit logs the commands of a split job (split, chunks, audio and merge)
+// without running them or creating the work dir. The chunk count is estimated
+// from the duration; real cuts follow keyframes and scene changes.
+func (c *Converter) dryRunSplit(ctx context.Context, inPath, finalOutPath string, mediaInfo *probe.MediaInfo, crf int) (string, error) {
+	segment := c.splitSegment()
+	n := 1
+	if mediaInfo != nil && mediaInfo.Duration > 0 {
+		n = max(1, int(math.Round(mediaInfo.Duration/float64(segment))))
+	}
+	job := &splitJob{dir: filepath.Join(c.Cfg.StatePath(), "split", "dry-run")}
+	sourceDir := filepath.Join(job.dir, "source")
+
+	log.Printf("[DryRun split] %s %v", c.ffmpegPath(), split.SplitArgs(inPath, sourceDir, segment))
+	for i := range n {
+		name := fmt.Sprintf("chunk_%03d", i)
+		in := filepath.Join(sourceDir, name+".mp4")
+		out := filepath.Join(job.dir, "converted", name+c.outputExt())
+		if err := c.convertFile(ctx, in, out, mediaInfo, crf, nil); err != nil {
+			return "", err
+		}
+	}
+	wantAudio := !c.Cfg.Mute && mediaInfo.HasAudio()
+	if wantAudio {
+		if err := c.encodeAudioTrack(ctx, inPath, job, mediaInfo); err != nil {
+			return "", err
+		}
+	}
+	listFile := filepath.Join(job.dir, "concat.txt")
+	log.Printf("[DryRun merge] %s %v", c.ffmpegPath(), c.mergeArgs(listFile, job, wantAudio, tempOutputPath(finalOutPath)))
+	return finalOutPath, nil
+}
+
 // Low level conversion logic
-func (c *Converter) convertFile(inPath, outPath string) error {
-	vf := "scale=1920:1080:force_original_aspect_ratio=decrease"
-	if !c.Cfg.NoPad {
-		vf += ",pad=1920:1080:(ow-iw)/2:(oh-ih)/2"
+// mediaInfo is the probe result of the original (unsplit) input, crf the encoder CRF (0 = from config).
+func (c *Converter) convertFile(ctx context.Context, inPath, outPath string, mediaInfo *probe.MediaInfo, crf int, onProgress func(time.Duration, float64)) error {
+	chunkArgs, err := c.chunkArgs(mediaInfo, crf)
+	if err != nil {
+		return err
 	}
+	ffmpegArgs := append([]string{"-i", inPath}, chunkArgs...)
+	ffmpegArgs = append(ffmpegArgs, "-y", outPath)
 
-	ffmpegPath := "ffmpeg"
-	if c.Cfg.FFmpegBin != "" {
-		ffmpegPath = c.Cfg.FFmpegBin
+	if c.Cfg.DryRun {
+		log.Printf("[DryRun chunk] %v", ffmpegArgs)
+		return nil
 	}
 
-	ffmpegArgs := []string{
-		"-i", inPath,
+	return c.runFFmpeg(ctx, ffmpegArgs, onProgress)
+}
+
+// chunkArgs returns the encoder options of a split chunk (everything between
+// input and output). Remote workers receive the same options.
+func (c *Converter) chunkArgs(mediaInfo *probe.MediaInfo, crf int) ([]string, error) {
+	vf, err := c.videoFilter(mediaInfo)
+	if err != nil {
+		return nil, err
 	}
 
 	// Codec Logic Reused
-	if c.Cfg.GPU {
-		ffmpegArgs = append(ffmpegArgs, "-c:v", "h264_videotoolbox")
-		q := 70
-		if c.Cfg.CRF > 0 {
-			q = 100 - (c.Cfg.CRF * 2)
-			if q < 1 {
-				q = 1
-			}
-		}
-		ffmpegArgs = append(ffmpegArgs, "-q:v", fmt.Sprintf("%d", q))
-	} else {
-		ffmpegArgs = append(ffmpegArgs, "-vcodec", "libx264")
-		ffmpegArgs = append(ffmpegArgs, "-preset", c.Cfg.Preset)
-		ffmpegArgs = append(ffmpegArgs, "-crf", fmt.Sprintf("%d", c.Cfg.CRF))
+	args, err := c.videoCodecArgs(crf)
+	if err != nil {
+		return nil, err
 	}
 
-	ffmpegArgs = append(ffmpegArgs,
-		"-vf", vf,
-		"-movflags", "+faststart",
-	)
+	if vf != "" {
+		args = append(args, "-vf", vf)
+	}
+	args = append(args, c.containerArgs()...)
+
+	// Audio is encoded once for the whole input (see encodeAudioTrack)
+	return append(args, "-an"), nil
+}
 
-	// Audio
-	if c.Cfg.Mute {
-		ffmpegArgs = append(ffmpegArgs, "-an")
+// encodeAudioTrack writes the audio of the whole input to the split job's audio track,
+// copying it when it already meets the target (see planStreams).
+func (c *Converter) encodeAudioTrack(ctx context.Context, inPath string, job *splitJob, mediaInfo *probe.MediaInfo) error {
+	outPath := job.audioPath()
+	tmpPath := tempOutputPath(outPath)
+
+	ffmpegArgs := []string{"-i", inPath, "-vn", "-map", "0:a:0"}
+	if c.planStreams(mediaInfo).copyAudio {
+		ffmpegArgs = append(ffmpegArgs, "-c:a", "copy")
 	} else {
-		ffmpegArgs = append(ffmpegArgs, "-acodec", "aac", "-b:a", "128k", "-ac", "2")
+		ffmpegArgs = append(ffmpegArgs, c.audioArgs(mediaInfo)...)
 	}
-
-	ffmpegArgs = append(ffmpegArgs, outPath)
+	ffmpegArgs = append(ffmpegArgs, "-y", tmpPath)
 
 	if c.Cfg.DryRun {
-		log.Printf("[DryRun chunk] %v", ffmpegArgs)
+		log.Printf("[DryRun audio] %v", ffmpegArgs)
 		return nil
 	}
 
-	cmd := exec.Command(ffmpegPath, ffmpegArgs...)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("ffmpeg error: %v\n%s", err, string(out))
+	if err := c.runFFmpeg(ctx, ffmpegArgs, nil); err != nil {
+		os.Remove(tmpPath)
+		return err
 	}
-	return nil
+	if err := commitOutput(tmpPath, outPath); err != nil {
+		return err
+	}
+	return job.setAudioDone()
 }
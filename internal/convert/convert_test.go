@@ -0,0 +1,79 @@
+package convert
+
+import (
+	"context"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/mt4110/rec-watch/internal/config"
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+// makeTestVideo renders a synthetic clip with video and a continuous tone.
+func makeTestVideo(t *testing.T, path string, seconds int) {
+	t.Helper()
+	for _, bin := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s not found", bin)
+		}
+	}
+	dur := strconv.Itoa(seconds)
+	cmd := exec.Command("ffmpeg", "-v", "error",
+		"-f", "lavfi", "-i", "testsrc2=size=320x240:rate=30:duration="+dur,
+		"-f", "lavfi", "-i", "sine=frequency=440:sample_rate=48000:duration="+dur,
+		"-c:v", "libx264", "-g", "30", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-ac", "2",
+		"-y", path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot create test video (ffmpeg without libx264/lavfi?): %v\n%s", err, out)
+	}
+}
+
+// Split mode must not change the length of the result: audio is encoded once and
+// muxed back, so there is no per-chunk priming delay adding up at the boundaries.
+func TestConvertSplitKeepsDuration(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.mp4")
+	makeTestVideo(t, in, 36)
+
+	cfg := config.NewDefault()
+	cfg.DestDir = filepath.Join(dir, "out")
+	cfg.StateDir = filepath.Join(dir, "state")
+	cfg.Resolution = "source"
+	cfg.FPS = 0
+	cfg.Codec = "libx264"
+	cfg.Preset = "ultrafast"
+	cfg.NoRemux = true
+	cfg.ParallelSplit = true
+	cfg.SplitSegmentSec = 10
+	cfg.NoTrash = true
+	cfg.Concurrent = 2
+
+	c := New(cfg)
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	out, err := c.Convert(context.Background(), in, dir)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	p := probe.New("")
+	src, err := p.Probe(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := p.Probe(context.Background(), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dst.HasVideo() || !dst.HasAudio() {
+		t.Fatalf("output is missing streams: %+v", dst)
+	}
+	if diff := math.Abs(dst.Duration - src.Duration); diff > 0.1 {
+		t.Errorf("duration changed: input %.3fs, output %.3fs", src.Duration, dst.Duration)
+	}
+}
@@ -0,0 +1,279 @@
+package convert
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/probe"
+	"github.com/mt4110/rec-watch/internal/split"
+)
+
+// CRF search settings: 3 samples of 8 seconds, at most 6 encodes per sample
+const (
+	crfSampleCount   = 3
+	crfSampleSec     = 8
+	crfSearchMaxStep = 6
+)
+
+// Search results are cached in <stateDir>/crf-cache/<id>.json, one file per
+// source version and search settings, and removed after crfCacheAge.
+const crfCacheAge = 30 * 24 * time.Hour
+
+// crfSearchResult is recorded in conversion_result as "crf_search".
+type crfSearchResult struct {
+	TargetVMAF float64         `json:"target_vmaf"`
+	CRF        int             `json:"crf"`  // encoder CRF used for the full encode
+	VMAF       float64         `json:"vmaf"` // mean sample VMAF at CRF
+	Tested     map[int]float64 `json:"tested"`
+	Reached    bool            `json:"reached"`          // false: even the lowest CRF missed the target
+	Cached     bool            `json:"cached,omitempty"` // taken from the cache, no samples encoded
+}
+
+// chosenCRF returns the CRF for videoCodecArgs (0 = from config when no search ran).
+func (r *crfSearchResult) chosenCRF() int {
+	if r == nil {
+		return 0
+	}
+	return r.CRF
+}
+
+// bisectCRF finds the highest CRF in [lo, hi] whose score is at least target,
+// assuming the score falls as the CRF rises. measure is called at most maxSteps times.
+// If no CRF reaches the target, the lowest CRF tried is returned with ok == false.
+func bisectCRF(lo, hi int, target float64, maxSteps int, measure func(crf int) (float64, error)) (best int, ok bool, err error) {
+	best = -1
+	lowest := hi + 1
+	for step := 0; lo <= hi && step < maxSteps; step++ {
+		mid := (lo + hi) / 2
+		score, err := measure(mid)
+		if err != nil {
+			return 0, false, err
+		}
+		lowest = min(lowest, mid)
+		if score >= target {
+			best = mid
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+	if best < 0 {
+		return lowest, false, nil
+	}
+	return best, true, nil
+}
+
+// searchCRF encodes short samples of inPath at several CRFs, measures their VMAF
+// and picks the highest CRF whose mean VMAF still meets TargetVMAF.
+func (c *Converter) searchCRF(ctx context.Context, inPath string, mediaInfo *probe.MediaInfo) (*crfSearchResult, error) {
+	if mediaInfo == nil {
+		return nil, fmt.Errorf("CRF探索には ffprobe の結果が必要です")
+	}
+	if c.Cfg.GPU {
+		return nil, fmt.Errorf("GPUエンコードはCRFを使わないため探索できません")
+	}
+	_, spec, err := c.videoCodec()
+	if err != nil {
+		return nil, err
+	}
+
+	vf, err := c.videoFilter(mediaInfo)
+	if err != nil {
+		return nil, err
+	}
+	refFilter := qualityRefFilter(vf, c.Cfg.FPS)
+
+	cachePath := c.crfCachePath(inPath, refFilter)
+	if cached := loadCRFCache(cachePath); cached != nil {
+		log.Printf("🎯 CRF %d を採用 (前回の探索結果, VMAF %.2f): %s", cached.CRF, cached.VMAF, filepath.Base(inPath))
+		return cached, nil
+	}
+
+	points := split.SamplePoints(mediaInfo.Duration, crfSampleCount, crfSampleSec)
+	log.Printf("🔎 CRF探索 (目標 VMAF %.1f, サンプル %d個): %s", c.Cfg.TargetVMAF, len(points), filepath.Base(inPath))
+	if c.Cfg.DryRun {
+		log.Printf("[DryRun] CRF探索をスキップします (設定のCRFを使用)")
+		return nil, nil
+	}
+
+	workDir, err := os.MkdirTemp("", "rec-watch-crf-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	// 1. Cut samples with the split package (stream copy)
+	s := split.New(c.Cfg.FFmpegBin)
+	var samples []string
+	for i, start := range points {
+		sample := filepath.Join(workDir, fmt.Sprintf("sample_%d.mkv", i))
+		if err := s.Sample(ctx, inPath, sample, start, crfSampleSec); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	// 2. Bisect over the codec's CRF range
+	result := &crfSearchResult{TargetVMAF: c.Cfg.TargetVMAF, Tested: map[int]float64{}}
+	measure := func(crf int) (float64, error) {
+		var total float64
+		for i, sample := range samples {
+			encoded := filepath.Join(workDir, fmt.Sprintf("sample_%d_crf%d.mkv", i, crf))
+			if err := c.encodeSample(ctx, sample, encoded, crf, mediaInfo); err != nil {
+				return 0, err
+			}
+			q, err := c.measureQuality(ctx, encoded, sample, refFilter, []string{"vmaf"})
+			if err != nil {
+				return 0, err
+			}
+			if q.VMAF == 0 {
+				return 0, fmt.Errorf("VMAFスコアを取得できません (libvmaf が有効な ffmpeg が必要です)")
+			}
+			total += q.VMAF
+			os.Remove(encoded)
+		}
+		score := total / float64(len(samples))
+		result.Tested[crf] = score
+		log.Printf("🔎 CRF %d: VMAF %.2f", crf, score)
+		return score, nil
+	}
+
+	crf, ok, err := bisectCRF(spec.searchCRF[0], spec.searchCRF[1], c.Cfg.TargetVMAF, crfSearchMaxStep, measure)
+	if err != nil {
+		return nil, err
+	}
+	result.CRF, result.VMAF, result.Reached = crf, result.Tested[crf], ok
+
+	if ok {
+		log.Printf("🎯 CRF %d を採用 (VMAF %.2f >= %.1f)", crf, result.VMAF, c.Cfg.TargetVMAF)
+	} else {
+		log.Printf("⚠️ 目標 VMAF %.1f に届きませんでした。CRF %d (VMAF %.2f) で変換します", c.Cfg.TargetVMAF, crf, result.VMAF)
+	}
+	saveCRFCache(cachePath, result)
+	return result, nil
+}
+
+// crfCachePath returns the cache file of a search on the current version of
+// inPath with the current codec, encoder options, filters and target ("" if
+// inPath cannot be identified).
+func (c *Converter) crfCachePath(inPath, refFilter string) string {
+	absIn, err := filepath.Abs(inPath)
+	if err != nil {
+		return ""
+	}
+	info, err := os.Stat(absIn)
+	if err != nil {
+		return ""
+	}
+	_, spec, err := c.videoCodec()
+	if err != nil {
+		return ""
+	}
+	// The encoder options at a fixed CRF, so that the configured CRF does not count
+	codecArgs, err := c.videoCodecArgs(spec.searchCRF[0])
+	if err != nil {
+		return ""
+	}
+	settings := strings.Join([]string{
+		strings.Join(codecArgs, " "),
+		refFilter,
+		fmt.Sprintf("target=%v samples=%dx%ds", c.Cfg.TargetVMAF, crfSampleCount, crfSampleSec),
+	}, "|")
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%s", absIn, info.Size(), info.ModTime().UnixNano(), settings)))
+	return filepath.Join(c.Cfg.StatePath(), "crf-cache", hex.EncodeToString(sum[:])[:16]+".json")
+}
+
+// loadCRFCache returns the cached result at path (nil if there is none).
+func loadCRFCache(path string) *crfSearchResult {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var result crfSearchResult
+	if json.Unmarshal(data, &result) != nil || result.CRF <= 0 {
+		return nil
+	}
+	result.Cached = true
+	return &result
+}
+
+// saveCRFCache writes result to path atomically and drops expired entries.
+func saveCRFCache(path string, result *crfSearchResult) {
+	if path == "" {
+		return
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("⚠️ CRF探索結果を保存できません: %v", err)
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("⚠️ CRF探索結果を保存できません: %v", err)
+		return
+	}
+	os.Rename(tmp, path)
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > crfCacheAge {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+// encodeSample encodes a sample with the same video settings as the full encode.
+func (c *Converter) encodeSample(ctx context.Context, inPath, outPath string, crf int, mediaInfo *probe.MediaInfo) error {
+	args := []string{"-i", inPath}
+	codecArgs, err := c.videoCodecArgs(crf)
+	if err != nil {
+		return err
+	}
+	args = append(args, codecArgs...)
+	vf, err := c.videoFilter(mediaInfo)
+	if err != nil {
+		return err
+	}
+	if vf != "" {
+		args = append(args, "-vf", vf)
+	}
+	if c.Cfg.FPS > 0 {
+		args = append(args, "-r", strconv.Itoa(c.Cfg.FPS))
+	}
+	args = append(args, "-an", "-y", outPath)
+	return c.runFFmpeg(ctx, args, nil)
+}
+
+// validateTargetVMAF is part of Validate.
+func (c *Converter) validateTargetVMAF() error {
+	if c.Cfg.TargetVMAF == 0 {
+		return nil
+	}
+	if c.Cfg.TargetVMAF < 0 || c.Cfg.TargetVMAF > 100 {
+		return fmt.Errorf("targetVmaf は 0-100 で指定してください: %v", c.Cfg.TargetVMAF)
+	}
+	if c.Cfg.TargetSize != "" {
+		return fmt.Errorf("targetVmaf と targetSize は同時に指定できません")
+	}
+	if c.Cfg.GPU {
+		return fmt.Errorf("targetVmaf はCPUエンコードでのみ使用できます (--gpu と併用不可)")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/config"
+)
+
+func TestBisectCRF(t *testing.T) {
+	// Synthetic quality curve: VMAF 100 at CRF 0, -1.5 per CRF step
+	curve := func(crf int) float64 { return 100 - 1.5*float64(crf) }
+
+	var calls int
+	measure := func(crf int) (float64, error) {
+		calls++
+		return curve(crf), nil
+	}
+
+	// VMAF >= 70 holds up to CRF 20
+	crf, ok, err := bisectCRF(14, 38, 70, 6, measure)
+	if err != nil || !ok || crf != 20 {
+		t.Errorf("bisectCRF() = %d, %v, %v; want 20, true", crf, ok, err)
+	}
+	if calls > 6 {
+		t.Errorf("measure called %d times, want <= 6", calls)
+	}
+
+	// Unreachable target: falls back to the lowest CRF tried
+	crf, ok, err = bisectCRF(14, 38, 99, 6, measure)
+	if err != nil || ok || crf != 14 {
+		t.Errorf("bisectCRF() = %d, %v, %v; want 14, false", crf, ok, err)
+	}
+}
+
+func TestCRFCache(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "in.mov")
+	os.WriteFile(src, []byte("source"), 0644)
+	c := New(&config.Config{Codec: "libx264", TargetVMAF: 93, StateDir: filepath.Join(dir, "state")})
+
+	path := c.crfCachePath(src, "scale=1280:720")
+	if path == "" || loadCRFCache(path) != nil {
+		t.Fatalf("unexpected cache entry at %q", path)
+	}
+	saveCRFCache(path, &crfSearchResult{TargetVMAF: 93, CRF: 24, VMAF: 93.4, Reached: true})
+	got := loadCRFCache(c.crfCachePath(src, "scale=1280:720"))
+	if got == nil || got.CRF != 24 || !got.Cached {
+		t.Fatalf("cached result = %+v, want CRF 24", got)
+	}
+
+	// The configured CRF is not part of the key, the search settings are
+	c.Cfg.CRF = 30
+	if c.crfCachePath(src, "scale=1280:720") != path {
+		t.Error("configured CRF changed the cache key")
+	}
+	c.Cfg.TargetVMAF = 90
+	if c.crfCachePath(src, "scale=1280:720") == path {
+		t.Error("target VMAF not part of the cache key")
+	}
+	c.Cfg.TargetVMAF = 93
+	if c.crfCachePath(src, "scale=1920:1080") == path {
+		t.Error("filters not part of the cache key")
+	}
+
+	// A new recording under the same name is searched again
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(src, later, later)
+	if c.crfCachePath(src, "scale=1280:720") == path {
+		t.Error("source version not part of the cache key")
+	}
+}
@@ -0,0 +1,128 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/probe"
+	"github.com/mt4110/rec-watch/internal/remote"
+)
+
+// Split chunks are encoded in slots: Concurrent local ones plus the slots each
+// configured worker (rec-watch worker) reports. A chunk whose worker is lost or
+// busy gives up that slot and takes the next free one, so the local slots are
+// always left as the fallback.
+
+// remoteWorker is a worker taking part in one split job.
+type remoteWorker struct {
+	url  string
+	lost atomic.Bool
+}
+
+// encodeSlot is a place to encode one chunk (worker == nil: this machine).
+type encodeSlot struct {
+	worker *remoteWorker
+}
+
+type slotPool struct {
+	slots chan *encodeSlot
+}
+
+// newSlotPool asks every configured worker for its slot count. Unreachable
+// workers are skipped for this job.
+func (c *Converter) newSlotPool(ctx context.Context) *slotPool {
+	local := c.Cfg.Concurrent
+	if local <= 0 {
+		local = 4
+	}
+
+	var slots []*encodeSlot
+	for range local {
+		slots = append(slots, &encodeSlot{})
+	}
+	if !c.Cfg.DryRun {
+		for _, url := range c.Cfg.Workers {
+			h, err := c.Remote.Health(ctx, url)
+			if err != nil {
+				log.Printf("⚠️ ワーカー %s に接続できません (このジョブでは使いません): %v", url, err)
+				continue
+			}
+			log.Printf("🌐 ワーカー %s: %d並列", url, h.Slots)
+			w := &remoteWorker{url: url}
+			for range h.Slots {
+				slots = append(slots, &encodeSlot{worker: w})
+			}
+		}
+	}
+
+	p := &slotPool{slots: make(chan *encodeSlot, len(slots))}
+	for _, s := range slots {
+		p.slots <- s
+	}
+	return p
+}
+
+// size is the number of chunks encoded at once.
+func (p *slotPool) size() int {
+	return cap(p.slots)
+}
+
+// acquire waits for a free slot, dropping slots of lost workers.
+func (p *slotPool) acquire(ctx context.Context) (*encodeSlot, error) {
+	for {
+		select {
+		case s := <-p.slots:
+			if s.worker != nil && s.worker.lost.Load() {
+				continue
+			}
+			return s, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release returns a slot (nil: none held).
+func (p *slotPool) release(s *encodeSlot) {
+	if s != nil {
+		p.slots <- s
+	}
+}
+
+// dispatchChunk encodes one chunk in slot, moving to another slot when the worker
+// is lost. It returns the slot the chunk ended up in, to be released by the caller.
+func (c *Converter) dispatchChunk(ctx context.Context, pool *slotPool, slot *encodeSlot, index int, inPath, outPath string, mediaInfo *probe.MediaInfo, crf int, onProgress func(time.Duration, float64)) (*encodeSlot, error) {
+	for {
+		if slot.worker == nil {
+			return slot, c.convertFile(ctx, inPath, outPath, mediaInfo, crf, onProgress)
+		}
+
+		args, err := c.chunkArgs(mediaInfo, crf)
+		if err != nil {
+			return slot, err
+		}
+		log.Printf("🌐 チャンク %d を %s で変換中", index, slot.worker.url)
+		err = c.Remote.Encode(ctx, slot.worker.url, inPath, outPath, args)
+		if !errors.Is(err, remote.ErrUnavailable) {
+			return slot, err
+		}
+
+		// Busy: give up only this slot. Lost: give up the whole worker.
+		if errors.Is(err, remote.ErrBusy) {
+			log.Printf("⚠️ ワーカー %s が混雑しているため、チャンク %d を別の場所で変換します", slot.worker.url, index)
+		} else if !slot.worker.lost.Swap(true) {
+			log.Printf("⚠️ ワーカー %s との接続が切れました。残りのチャンクは他のワーカーかローカルで変換します: %v", slot.worker.url, err)
+		}
+		slot, err = pool.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if slot.worker == nil {
+			log.Printf("💻 チャンク %d をローカルで変換します: %s", index, filepath.Base(inPath))
+		}
+	}
+}
@@ -0,0 +1,114 @@
+//go:build !windows
+
+package convert
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/config"
+	"github.com/mt4110/rec-watch/internal/remote"
+)
+
+// fakeEncoder writes a script that stores name in its output file (the last argument).
+func fakeEncoder(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ffmpeg-"+name)
+	script := "#!/bin/sh\nfor a; do out=$a; done\necho " + name + " > \"$out\"\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewSlotPool(t *testing.T) {
+	worker := httptest.NewServer(remote.NewServer(fakeEncoder(t, "remote"), "", 2).Handler())
+	defer worker.Close()
+	gone := httptest.NewServer(remote.NewServer(fakeEncoder(t, "gone"), "", 2).Handler())
+	gone.Close()
+
+	c := New(&config.Config{Concurrent: 1, Workers: []string{gone.URL, worker.URL}})
+	if got := c.newSlotPool(context.Background()).size(); got != 3 {
+		t.Errorf("pool size = %d, want 1 local + 2 remote (unreachable worker skipped)", got)
+	}
+}
+
+func TestDispatchChunk(t *testing.T) {
+	worker := httptest.NewServer(remote.NewServer(fakeEncoder(t, "remote"), "", 2).Handler())
+	defer worker.Close()
+	lost := httptest.NewServer(remote.NewServer(fakeEncoder(t, "lost"), "", 2).Handler())
+	lost.Close()
+
+	c := New(&config.Config{
+		Codec:     "libx264",
+		CRF:       22,
+		Preset:    "faster",
+		FFmpegBin: fakeEncoder(t, "local"),
+	})
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "chunk_000.mp4")
+	if err := os.WriteFile(in, []byte("chunk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	encode := func(pool *slotPool, slot *encodeSlot) (string, *encodeSlot) {
+		t.Helper()
+		out := filepath.Join(dir, "out.mp4")
+		slot, err := c.dispatchChunk(ctx, pool, slot, 0, in, out, nil, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(data)), slot
+	}
+
+	// A chunk on a lost worker moves on; the worker's other slot is dropped
+	lostWorker := &remoteWorker{url: lost.URL}
+	pool := &slotPool{slots: make(chan *encodeSlot, 3)}
+	pool.release(&encodeSlot{worker: lostWorker})
+	pool.release(&encodeSlot{})
+	got, slot := encode(pool, &encodeSlot{worker: lostWorker})
+	if got != "local" || slot.worker != nil {
+		t.Errorf("lost worker chunk encoded by %q, want local fallback", got)
+	}
+	if !lostWorker.lost.Load() {
+		t.Error("worker must be marked lost")
+	}
+	if len(pool.slots) != 0 {
+		t.Errorf("%d slots left in pool, want 0", len(pool.slots))
+	}
+
+	// A worker that stops answering is dropped after the idle timeout
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer hung.Close()
+	c.Remote.IdleTimeout = 200 * time.Millisecond
+	hungWorker := &remoteWorker{url: hung.URL}
+	pool.release(&encodeSlot{})
+	got, slot = encode(pool, &encodeSlot{worker: hungWorker})
+	if got != "local" || slot.worker != nil {
+		t.Errorf("hung worker chunk encoded by %q, want local fallback", got)
+	}
+	if !hungWorker.lost.Load() {
+		t.Error("hung worker must be marked lost")
+	}
+	c.Remote.IdleTimeout = remote.DefaultIdleTimeout
+
+	// A live worker encodes remotely
+	if got, _ := encode(pool, &encodeSlot{worker: &remoteWorker{url: worker.URL}}); got != "remote" {
+		t.Errorf("worker slot encoded by %q", got)
+	}
+}
@@ -0,0 +1,192 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Marker inserted into temp file names. Files are also dot-prefixed so that
+// Finder, sync clients and the watcher itself ignore them.
+const partialMarker = ".partial"
+
+// tempOutputPath returns the hidden file ffmpeg writes to before the final rename,
+// e.g. out/2024-01-01_10-00-00.mp4 -> out/.2024-01-01_10-00-00.partial.mp4
+// The real extension is kept so that ffmpeg still picks the right muxer.
+func tempOutputPath(outPath string) string {
+	dir, base := filepath.Split(outPath)
+	ext := filepath.Ext(base)
+	return filepath.Join(dir, "."+strings.TrimSuffix(base, ext)+partialMarker+ext)
+}
+
+// commitOutput atomically moves a finished temp file to its final name.
+// Both live in the same directory, so os.Rename is atomic.
+func commitOutput(tmpPath, outPath string) error {
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("出力ファイルの確定に失敗: %w", err)
+	}
+	return nil
+}
+
+// Default output names. {date}/{time} come from the source ModTime.
+const (
+	defaultNameTemplate      = "{date}_{time}.{ext}"
+	defaultStampNameTemplate = "{date}_{time}_{stem}.{ext}" // stampPerFile: true
+)
+
+// Conflict policies for an already existing output (config: onConflict)
+const (
+	ConflictSuffix    = "suffix" // name-1.mp4, name-2.mp4 ... (default)
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+)
+
+// ErrOutputExists is returned with onConflict: skip when the target already exists.
+var ErrOutputExists = errors.New("出力ファイルが既に存在するためスキップしました")
+
+// placeholderRe matches a {key} placeholder together with the separator before it.
+var placeholderRe = regexp.MustCompile(`([_\-. ]?)\{(\w+)\}`)
+
+// expandTemplate fills in a name template such as "{date}_{time}_{stem}_{profile}.{ext}".
+// An empty placeholder (e.g. no profile) is dropped together with the separator before it.
+// Placeholders are replaced in a single pass, so values containing "{...}" stay as they are.
+func expandTemplate(tmpl string, vars map[string]string) string {
+	name := placeholderRe.ReplaceAllStringFunc(tmpl, func(m string) string {
+		sub := placeholderRe.FindStringSubmatch(m)
+		v, ok := vars[sub[2]]
+		switch {
+		case !ok:
+			return m
+		case v == "":
+			return ""
+		}
+		// Values must never introduce directories
+		return sub[1] + strings.NewReplacer("/", "_", "\\", "_").Replace(v)
+	})
+
+	ext := filepath.Ext(name)
+	stem := strings.Trim(strings.TrimSuffix(name, ext), "_-. ")
+	if stem == "" {
+		stem = "output"
+	}
+	return stem + ext
+}
+
+// outputName builds the output file name for inPath from the configured template.
+func (c *Converter) outputName(inPath string) string {
+	modTime := time.Now()
+	if info, err := os.Stat(inPath); err == nil {
+		modTime = info.ModTime()
+	}
+
+	tmpl := c.Cfg.NameTemplate
+	if tmpl == "" {
+		tmpl = defaultNameTemplate
+		if c.Cfg.StampPerFile {
+			tmpl = defaultStampNameTemplate
+		}
+	}
+
+	base := filepath.Base(inPath)
+	codec, _, _ := c.videoCodec()
+	return expandTemplate(tmpl, map[string]string{
+		"date":    modTime.Format("2006-01-02"),
+		"time":    modTime.Format("15-04-05"),
+		"stem":    strings.TrimSuffix(base, filepath.Ext(base)),
+		"profile": c.Cfg.ProfileName,
+		"codec":   codec,
+		"ext":     strings.TrimPrefix(c.outputExt(), "."),
+	})
+}
+
+// ExistingOutput returns the output an earlier conversion of inPath left in
+// destDir or in one of its batch folders ("" if there is none). Only the name
+// the template gives inPath is checked, not the suffixed ones.
+func (c *Converter) ExistingOutput(inPath string) string {
+	destDir, err := filepath.Abs(c.Cfg.DestDir)
+	if err != nil {
+		return ""
+	}
+	name := c.outputName(inPath)
+	candidates := []string{filepath.Join(destDir, name)}
+	entries, _ := os.ReadDir(destDir)
+	for _, e := range entries {
+		if e.IsDir() {
+			candidates = append(candidates, filepath.Join(destDir, e.Name(), name))
+		}
+	}
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// reserveOutput picks the final output path in outDir according to onConflict.
+// The name stays reserved until release is called, so that parallel jobs finishing
+// in the same second never pick the same name while ffmpeg is still writing.
+func (c *Converter) reserveOutput(inPath, outDir string) (outPath string, release func(), err error) {
+	name := c.outputName(inPath)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	r := c.reserved
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	taken := func(p string) bool {
+		if r.paths[p] {
+			return true
+		}
+		_, err := os.Stat(p)
+		return err == nil
+	}
+
+	outPath = filepath.Join(outDir, name)
+	switch c.Cfg.OnConflict {
+	case ConflictOverwrite:
+		// Only files on disk are replaced; a name another job is still writing
+		// to gets a suffix, as both would share the temp file
+		for n := 1; r.paths[outPath]; n++ {
+			outPath = filepath.Join(outDir, fmt.Sprintf("%s-%d%s", stem, n, ext))
+		}
+	case ConflictSkip:
+		if taken(outPath) {
+			return "", nil, fmt.Errorf("%w: %s", ErrOutputExists, outPath)
+		}
+	default:
+		for n := 1; taken(outPath); n++ {
+			outPath = filepath.Join(outDir, fmt.Sprintf("%s-%d%s", stem, n, ext))
+		}
+	}
+
+	r.paths[outPath] = true
+	release = func() {
+		r.mu.Lock()
+		delete(r.paths, outPath)
+		r.mu.Unlock()
+	}
+	return outPath, release, nil
+}
+
+// validateNaming checks template and conflict policy (used by Validate).
+func (c *Converter) validateNaming() error {
+	switch c.Cfg.OnConflict {
+	case "", ConflictSuffix, ConflictSkip, ConflictOverwrite:
+	default:
+		return fmt.Errorf("onConflict の指定が不正です: %s (suffix, skip, overwrite)", c.Cfg.OnConflict)
+	}
+	if c.Cfg.NameTemplate != "" && strings.ContainsAny(c.Cfg.NameTemplate, `/\`) {
+		return fmt.Errorf("nameTemplate にディレクトリ区切りは使えません: %s", c.Cfg.NameTemplate)
+	}
+	if c.Cfg.NameTemplate != "" && !strings.Contains(c.Cfg.NameTemplate, "{ext}") {
+		return fmt.Errorf("nameTemplate には {ext} が必要です: %s", c.Cfg.NameTemplate)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package convert
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mt4110/rec-watch/internal/config"
+)
+
+func TestTempOutputPath(t *testing.T) {
+	got := tempOutputPath(filepath.Join("out", "2024-01-01_10-00-00.mp4"))
+	want := filepath.Join("out", ".2024-01-01_10-00-00.partial.mp4")
+	if got != want {
+		t.Errorf("tempOutputPath() = %q, want %q", got, want)
+	}
+}
+
+func TestCommitOutput(t *testing.T) {
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "out.mp4")
+	tmpPath := tempOutputPath(outPath)
+	if err := os.WriteFile(tmpPath, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := commitOutput(tmpPath, outPath); err != nil {
+		t.Fatalf("commitOutput failed: %v", err)
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Errorf("temp file should be gone, stat err = %v", err)
+	}
+	if b, err := os.ReadFile(outPath); err != nil || string(b) != "data" {
+		t.Errorf("unexpected output content %q (err %v)", b, err)
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	vars := map[string]string{
+		"date":    "2024-01-01",
+		"time":    "10-00-00",
+		"stem":    "Screen Recording",
+		"profile": "",
+		"ext":     "mp4",
+	}
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{defaultNameTemplate, "2024-01-01_10-00-00.mp4"},
+		{"{date}_{time}_{stem}_{profile}.{ext}", "2024-01-01_10-00-00_Screen Recording.mp4"},
+		{"{profile}_{stem}.{ext}", "Screen Recording.mp4"},
+		{"{profile}.{ext}", "output.mp4"},
+	}
+	for _, tt := range tests {
+		if got := expandTemplate(tt.tmpl, vars); got != tt.want {
+			t.Errorf("expandTemplate(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+
+	vars["profile"] = "youtube"
+	if got := expandTemplate("{stem}_{profile}.{ext}", vars); got != "Screen Recording_youtube.mp4" {
+		t.Errorf("unexpected name with profile: %q", got)
+	}
+
+	// A value is never expanded again
+	vars["stem"] = "clip_{date}"
+	for i := 0; i < 20; i++ {
+		if got := expandTemplate("{stem}_{time}.{ext}", vars); got != "clip_{date}_10-00-00.mp4" {
+			t.Fatalf("placeholder in value expanded: %q", got)
+		}
+	}
+}
+
+func TestValidateNaming(t *testing.T) {
+	for tmpl, ok := range map[string]bool{
+		"":                    true,
+		"{stem}.{ext}":        true,
+		"{stem}":              false,
+		"{date}/{stem}.{ext}": false,
+	} {
+		c := New(&config.Config{NameTemplate: tmpl})
+		if err := c.validateNaming(); (err == nil) != ok {
+			t.Errorf("validateNaming(%q) = %v", tmpl, err)
+		}
+	}
+}
+
+func TestReserveOutput(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "in.mov")
+	if err := os.WriteFile(src, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(dir, "out")
+	os.MkdirAll(outDir, 0755)
+
+	c := New(&config.Config{NameTemplate: "{stem}.{ext}"})
+
+	first, release1, err := c.reserveOutput(src, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Reserved but not yet written: a parallel job must get another name
+	second, release2, err := c.reserveOutput(src, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(first) != "in.mp4" || filepath.Base(second) != "in-1.mp4" {
+		t.Errorf("expected in.mp4 / in-1.mp4, got %s / %s", filepath.Base(first), filepath.Base(second))
+	}
+	release1()
+	release2()
+
+	// Existing file with skip policy
+	os.WriteFile(first, nil, 0644)
+	c.Cfg.OnConflict = ConflictSkip
+	if _, _, err := c.reserveOutput(src, outDir); !errors.Is(err, ErrOutputExists) {
+		t.Errorf("expected ErrOutputExists, got %v", err)
+	}
+
+	c.Cfg.OnConflict = ConflictOverwrite
+	got, release, err := c.reserveOutput(src, outDir)
+	if err != nil || got != first {
+		t.Fatalf("expected overwrite of %s, got %s (err %v)", first, got, err)
+	}
+	// A name a parallel job is still writing to is not shared
+	if other, release2, err := c.reserveOutput(src, outDir); err != nil || other == first {
+		t.Errorf("expected another name than %s, got %s (err %v)", first, other, err)
+	} else {
+		release2()
+	}
+	release()
+}
@@ -0,0 +1,271 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/probe"
+	"github.com/mt4110/rec-watch/internal/proc"
+)
+
+// Progress is a snapshot of a running conversion, built from ffmpeg's -progress output.
+type Progress struct {
+	Input    string
+	Percent  float64       // 0-100, -1 if the input duration is unknown
+	OutTime  time.Duration // encoded media time so far
+	Duration time.Duration // probed input duration (0 if unknown)
+	Speed    float64       // x realtime (sum over chunks in split mode)
+	ETA      time.Duration // 0 if unknown
+	Done     bool
+}
+
+// ProgressFunc receives progress updates. It is called from ffmpeg reader goroutines,
+// so implementations must be safe for concurrent use and must not block.
+type ProgressFunc func(Progress)
+
+// Minimum interval between two ProgressFunc calls for the same input
+const progressInterval = 500 * time.Millisecond
+
+// progressReporter aggregates ffmpeg progress for one input. In split mode every
+// chunk reports as a separate part and the encoded times are summed up.
+type progressReporter struct {
+	c        *Converter
+	input    string
+	duration time.Duration
+
+	mu         sync.Mutex
+	outTimes   map[int]time.Duration
+	speeds     map[int]float64
+	lastEmit   time.Time
+	lastLogged int // last logged 10% step
+}
+
+func (c *Converter) newProgress(input string, mediaInfo *probe.MediaInfo) *progressReporter {
+	p := &progressReporter{
+		c:          c,
+		input:      input,
+		outTimes:   map[int]time.Duration{},
+		speeds:     map[int]float64{},
+		lastLogged: -1,
+	}
+	if mediaInfo != nil && mediaInfo.Duration > 0 {
+		p.duration = time.Duration(mediaInfo.Duration * float64(time.Second))
+	}
+	return p
+}
+
+// part returns the ffmpeg progress callback for one part (chunk index, 0 for single files).
+func (p *progressReporter) part(i int) func(outTime time.Duration, speed float64) {
+	return func(outTime time.Duration, speed float64) {
+		p.update(i, outTime, speed)
+	}
+}
+
+// endPart marks a part as finished so that its speed no longer counts.
+func (p *progressReporter) endPart(i int) {
+	p.mu.Lock()
+	delete(p.speeds, i)
+	p.mu.Unlock()
+}
+
+func (p *progressReporter) update(part int, outTime time.Duration, speed float64) {
+	p.mu.Lock()
+	p.outTimes[part] = outTime
+	if speed > 0 {
+		p.speeds[part] = speed
+	}
+	snap := p.snapshot()
+
+	emit := time.Since(p.lastEmit) >= progressInterval
+	if emit {
+		p.lastEmit = time.Now()
+	}
+	step := -1
+	if snap.Percent >= 0 {
+		step = int(snap.Percent) / 10
+	}
+	logIt := step > p.lastLogged && step < 10
+	if logIt {
+		p.lastLogged = step
+	}
+	p.mu.Unlock()
+
+	if logIt && step > 0 {
+		log.Printf("⏳ %s %s", formatProgress(snap), filepath.Base(p.input))
+	}
+	if emit && p.c.OnProgress != nil {
+		p.c.OnProgress(snap)
+	}
+}
+
+// finish emits the final 100% update.
+func (p *progressReporter) finish() {
+	p.mu.Lock()
+	snap := p.snapshot()
+	p.mu.Unlock()
+
+	snap.Done = true
+	snap.ETA = 0
+	if snap.Duration > 0 {
+		snap.Percent = 100
+		snap.OutTime = snap.Duration
+	}
+	if p.c.OnProgress != nil {
+		p.c.OnProgress(snap)
+	}
+}
+
+// snapshot must be called with p.mu held.
+func (p *progressReporter) snapshot() Progress {
+	var total time.Duration
+	for _, t := range p.outTimes {
+		total += t
+	}
+	var speed float64
+	for _, s := range p.speeds {
+		speed += s
+	}
+
+	pr := Progress{
+		Input:    p.input,
+		Percent:  -1,
+		OutTime:  total,
+		Duration: p.duration,
+		Speed:    speed,
+	}
+	if p.duration > 0 {
+		pr.Percent = min(float64(total)/float64(p.duration)*100, 100)
+		if speed > 0 && total < p.duration {
+			pr.ETA = time.Duration(float64(p.duration-total) / speed)
+		}
+	}
+	return pr
+}
+
+// formatProgress renders e.g. "42.0% (1.8x, 残り 2m10s)".
+func formatProgress(pr Progress) string {
+	if pr.Percent < 0 {
+		return fmt.Sprintf("%s (%.1fx)", pr.OutTime.Truncate(time.Second), pr.Speed)
+	}
+	s := fmt.Sprintf("%.1f%%", pr.Percent)
+	if pr.Speed > 0 {
+		s += fmt.Sprintf(" (%.1fx", pr.Speed)
+		if pr.ETA > 0 {
+			s += fmt.Sprintf(", 残り %s", pr.ETA.Truncate(time.Second))
+		}
+		s += ")"
+	}
+	return s
+}
+
+// parseProgress reads ffmpeg "-progress pipe:1" key=value blocks and calls fn
+// at the end of every block.
+func parseProgress(r io.Reader, fn func(outTime time.Duration, speed float64)) {
+	var outTime time.Duration
+	var speed float64
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "out_time_us", "out_time_ms":
+			// out_time_ms is (despite its name) also in microseconds
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+				outTime = time.Duration(us) * time.Microsecond
+			}
+		case "speed":
+			if v, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64); err == nil {
+				speed = v
+			}
+		case "progress":
+			if fn != nil {
+				fn(outTime, speed)
+			}
+		}
+	}
+}
+
+// Keep at most this much of ffmpeg's stderr for error messages
+const stderrTailSize = 16 * 1024
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (t *tailBuffer) Write(b []byte) (int, error) {
+	t.buf.Write(b)
+	if over := t.buf.Len() - t.max; over > 0 {
+		t.buf.Next(over)
+	}
+	return len(b), nil
+}
+
+func (t *tailBuffer) String() string {
+	return t.buf.String()
+}
+
+// runFFmpeg runs ffmpeg with progress reporting on stdout.
+// onProgress may be nil. stderr is returned in the error message on failure.
+// Cancelling ctx kills ffmpeg (and its process group) and returns ctx.Err().
+func (c *Converter) runFFmpeg(ctx context.Context, args []string, onProgress func(outTime time.Duration, speed float64)) error {
+	_, err := c.runFFmpegStderr(ctx, args, onProgress)
+	return err
+}
+
+// runFFmpegStderr is runFFmpeg that also returns the tail of stderr on success,
+// for filters that print their results there (ssim, psnr, libvmaf).
+//
+// Every ffmpeg run waits for a slot of the converter's Scheduler, and gets its
+// thread share as -threads (an output option, so it goes before the last argument).
+func (c *Converter) runFFmpegStderr(ctx context.Context, args []string, onProgress func(outTime time.Duration, speed float64)) (string, error) {
+	threads, release, err := c.Sched.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	fullArgs := append([]string{"-nostats", "-progress", "pipe:1"}, args[:len(args)-1]...)
+	fullArgs = append(fullArgs, "-threads", strconv.Itoa(threads), args[len(args)-1])
+	cmd := proc.Command(ctx, c.ffmpegPath(), fullArgs...)
+
+	stderr := &tailBuffer{max: stderrTailSize}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("ffmpeg起動エラー: %v", err)
+	}
+	parseProgress(stdout, onProgress)
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("ffmpeg実行エラー: %v\n%s", err, stderr.String())
+	}
+	return stderr.String(), nil
+}
+
+func (c *Converter) ffmpegPath() string {
+	if c.Cfg.FFmpegBin != "" {
+		return c.Cfg.FFmpegBin
+	}
+	return "ffmpeg"
+}
@@ -0,0 +1,76 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/config"
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+func TestParseProgress(t *testing.T) {
+	input := `frame=120
+fps=60.0
+out_time_us=4000000
+out_time_ms=4000000
+out_time=00:00:04.000000
+speed=2.00x
+progress=continue
+frame=240
+out_time_us=N/A
+speed=N/A
+progress=continue
+out_time_us=8000000
+speed=1.5x
+progress=end
+`
+	type update struct {
+		outTime time.Duration
+		speed   float64
+	}
+	var got []update
+	parseProgress(strings.NewReader(input), func(outTime time.Duration, speed float64) {
+		got = append(got, update{outTime, speed})
+	})
+
+	want := []update{
+		{4 * time.Second, 2.0},
+		{4 * time.Second, 2.0}, // N/A keeps the previous values
+		{8 * time.Second, 1.5},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d updates, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("update %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestProgressReporter_SplitParts(t *testing.T) {
+	var last Progress
+	c := New(&config.Config{})
+	c.OnProgress = func(p Progress) { last = p }
+
+	p := c.newProgress("in.mov", &probe.MediaInfo{Duration: 100})
+	p.update(0, 20*time.Second, 1.0)
+	p.lastEmit = time.Time{} // bypass throttling
+	p.update(1, 30*time.Second, 1.5)
+
+	if last.Percent != 50 {
+		t.Errorf("expected 50%%, got %v", last.Percent)
+	}
+	if last.Speed != 2.5 {
+		t.Errorf("expected summed speed 2.5, got %v", last.Speed)
+	}
+	if last.ETA != 20*time.Second {
+		t.Errorf("expected ETA 20s, got %v", last.ETA)
+	}
+
+	p.finish()
+	if !last.Done || last.Percent != 100 {
+		t.Errorf("expected done at 100%%, got %+v", last)
+	}
+}
@@ -0,0 +1,163 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+// QualityScores holds perceptual quality metrics of an output compared to its source.
+// Zero values mean "not measured".
+type QualityScores struct {
+	VMAF float64 `json:"vmaf,omitempty"`
+	SSIM float64 `json:"ssim,omitempty"`
+	PSNR float64 `json:"psnr,omitempty"`
+}
+
+// Supported values of config "quality"
+var qualityMetrics = []string{"vmaf", "ssim", "psnr"}
+
+func validateQuality(metrics []string) error {
+	for _, m := range metrics {
+		if !slices.Contains(qualityMetrics, strings.ToLower(m)) {
+			return fmt.Errorf("不明な品質指標です: %s (対応: %s)", m, strings.Join(qualityMetrics, ", "))
+		}
+	}
+	return nil
+}
+
+// qualityRefFilter returns the filter chain that turns the source into the same
+// geometry and frame rate as the output, so that frames can be compared 1:1.
+func qualityRefFilter(vf string, fps int) string {
+	var filters []string
+	if vf != "" {
+		filters = append(filters, vf)
+	}
+	if fps > 0 {
+		filters = append(filters, fmt.Sprintf("fps=%d", fps))
+	}
+	return strings.Join(filters, ",")
+}
+
+// qualityGraph builds the -lavfi graph. Input 0 is the distorted (converted) file,
+// input 1 the reference (source), scaled with refFilter.
+func qualityGraph(metrics []string, refFilter string) string {
+	n := len(metrics)
+	ref := "[1:v]"
+	if refFilter != "" {
+		ref += refFilter + ","
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[0:v]format=yuv420p,setpts=PTS-STARTPTS,split=%d", n)
+	for i := range n {
+		fmt.Fprintf(&b, "[d%d]", i)
+	}
+	fmt.Fprintf(&b, ";%sformat=yuv420p,setpts=PTS-STARTPTS,split=%d", ref, n)
+	for i := range n {
+		fmt.Fprintf(&b, "[r%d]", i)
+	}
+	for i, m := range metrics {
+		fmt.Fprintf(&b, ";[d%d][r%d]", i, i)
+		if m == "vmaf" {
+			fmt.Fprintf(&b, "libvmaf=n_threads=%d", runtime.NumCPU())
+		} else {
+			b.WriteString(m)
+		}
+	}
+	return b.String()
+}
+
+var (
+	vmafScoreRe = regexp.MustCompile(`VMAF score[:=]\s*([0-9.]+)`)
+	ssimScoreRe = regexp.MustCompile(`SSIM .*All:([0-9.]+)`)
+	psnrScoreRe = regexp.MustCompile(`PSNR .*average:([0-9.]+|inf)`)
+)
+
+// parseQuality extracts the summary lines that ffmpeg's libvmaf/ssim/psnr filters
+// print to stderr when the graph finishes.
+func parseQuality(stderr string) QualityScores {
+	var q QualityScores
+	parse := func(re *regexp.Regexp) float64 {
+		m := re.FindAllStringSubmatch(stderr, -1)
+		if len(m) == 0 {
+			return 0
+		}
+		v, err := strconv.ParseFloat(m[len(m)-1][1], 64)
+		if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+			// identical frames give PSNR "inf", which JSON cannot hold
+			return 0
+		}
+		return v
+	}
+	q.VMAF = parse(vmafScoreRe)
+	q.SSIM = parse(ssimScoreRe)
+	q.PSNR = parse(psnrScoreRe)
+	return q
+}
+
+// measureQuality compares distorted against reference with the given metrics.
+func (c *Converter) measureQuality(ctx context.Context, distorted, reference, refFilter string, metrics []string) (QualityScores, error) {
+	args := []string{
+		"-i", distorted,
+		"-i", reference,
+		"-lavfi", qualityGraph(metrics, refFilter),
+		"-f", "null", os.DevNull,
+	}
+	stderr, err := c.runFFmpegStderr(ctx, args, nil)
+	if err != nil {
+		return QualityScores{}, err
+	}
+	return parseQuality(stderr), nil
+}
+
+// qualityReport runs the configured quality metrics for a finished conversion.
+// Measurement problems (e.g. ffmpeg built without libvmaf) are only logged.
+func (c *Converter) qualityReport(ctx context.Context, inPath, outPath, refFilter string, mediaInfo *probe.MediaInfo) *QualityScores {
+	if len(c.Cfg.Quality) == 0 || c.Cfg.DryRun || mediaInfo == nil {
+		return nil
+	}
+	metrics := make([]string, 0, len(c.Cfg.Quality))
+	for _, m := range c.Cfg.Quality {
+		metrics = append(metrics, strings.ToLower(m))
+	}
+
+	log.Printf("📏 品質を測定中 (%s): %s", strings.Join(metrics, "/"), filepath.Base(outPath))
+	q, err := c.measureQuality(ctx, outPath, inPath, refFilter, metrics)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("⚠️ 品質測定に失敗しました: %v", err)
+		}
+		return nil
+	}
+	log.Printf("📏 %s: %s", filepath.Base(outPath), formatQuality(q))
+	return &q
+}
+
+// formatQuality renders e.g. "VMAF 94.12 / SSIM 0.9812 / PSNR 41.30dB".
+func formatQuality(q QualityScores) string {
+	var parts []string
+	if q.VMAF > 0 {
+		parts = append(parts, fmt.Sprintf("VMAF %.2f", q.VMAF))
+	}
+	if q.SSIM > 0 {
+		parts = append(parts, fmt.Sprintf("SSIM %.4f", q.SSIM))
+	}
+	if q.PSNR > 0 {
+		parts = append(parts, fmt.Sprintf("PSNR %.2fdB", q.PSNR))
+	}
+	if len(parts) == 0 {
+		return "スコアなし"
+	}
+	return strings.Join(parts, " / ")
+}
@@ -0,0 +1,47 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseQuality(t *testing.T) {
+	stderr := `Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'out.mp4':
+[Parsed_libvmaf_6 @ 0x600003a04000] VMAF score: 94.183252
+[Parsed_ssim_7 @ 0x600003a04100] SSIM Y:0.987401 (18.997651) U:0.992132 (21.041310) V:0.991801 (20.863811) All:0.989093 (19.621447)
+[Parsed_psnr_8 @ 0x600003a04200] PSNR y:42.071203 u:46.891432 v:47.012145 average:43.377521 min:38.112322 max:51.003412
+`
+	q := parseQuality(stderr)
+	if q.VMAF != 94.183252 || q.SSIM != 0.989093 || q.PSNR != 43.377521 {
+		t.Errorf("parseQuality() = %+v", q)
+	}
+
+	// Identical input gives PSNR inf, which must not end up in JSON
+	q = parseQuality("[Parsed_psnr_0 @ 0x1] PSNR y:inf u:inf v:inf average:inf min:inf max:inf\n")
+	if q.PSNR != 0 {
+		t.Errorf("expected PSNR inf to be dropped, got %v", q.PSNR)
+	}
+}
+
+func TestQualityGraph(t *testing.T) {
+	got := qualityGraph([]string{"ssim", "psnr"}, qualityRefFilter("scale=1280:720", 30))
+	want := "[0:v]format=yuv420p,setpts=PTS-STARTPTS,split=2[d0][d1];" +
+		"[1:v]scale=1280:720,fps=30,format=yuv420p,setpts=PTS-STARTPTS,split=2[r0][r1];" +
+		"[d0][r0]ssim;[d1][r1]psnr"
+	if got != want {
+		t.Errorf("qualityGraph() =\n%s\nwant\n%s", got, want)
+	}
+
+	if g := qualityGraph([]string{"vmaf"}, ""); !strings.Contains(g, "[1:v]format=yuv420p") || !strings.Contains(g, "[d0][r0]libvmaf") {
+		t.Errorf("unexpected vmaf graph: %s", g)
+	}
+}
+
+func TestValidateQuality(t *testing.T) {
+	if err := validateQuality([]string{"VMAF", "ssim", "psnr"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateQuality([]string{"butteraugli"}); err == nil {
+		t.Error("expected error for unknown metric")
+	}
+}
@@ -0,0 +1,75 @@
+package convert
+
+import (
+	"math"
+
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+// streamPlan tells which streams can be copied as is instead of re-encoded.
+type streamPlan struct {
+	copyVideo bool
+	copyAudio bool
+}
+
+// mode is the name recorded in conversion_result.
+func (p streamPlan) mode() string {
+	switch {
+	case p.copyVideo && p.copyAudio:
+		return "remux"
+	case p.copyVideo:
+		return "copy_video"
+	case p.copyAudio:
+		return "copy_audio"
+	default:
+		return "encode"
+	}
+}
+
+// planStreams decides per file whether the probed streams already meet the target
+// (codec, size, frame rate, pixel format / audio codec and channels).
+// Without probe info (DryRun without ffprobe) everything is re-encoded.
+func (c *Converter) planStreams(mediaInfo *probe.MediaInfo) streamPlan {
+	if c.Cfg.NoRemux || !mediaInfo.HasVideo() {
+		return streamPlan{}
+	}
+	return streamPlan{
+		copyVideo: c.videoMeetsTarget(mediaInfo),
+		copyAudio: c.audioMeetsTarget(mediaInfo),
+	}
+}
+
+func (c *Converter) videoMeetsTarget(mediaInfo *probe.MediaInfo) bool {
+	_, spec, err := c.videoCodec()
+	if err != nil {
+		return false
+	}
+	v := mediaInfo.Video
+
+	if v.Codec != spec.family {
+		return false
+	}
+	// Players and browsers only reliably decode 8bit 4:2:0
+	if v.PixFmt != "yuv420p" {
+		return false
+	}
+	if c.Cfg.FPS > 0 && math.Abs(v.FrameRate-float64(c.Cfg.FPS)) > 0.1 {
+		return false
+	}
+	// No scale / pad needed for the configured resolution
+	vf, err := c.videoFilter(mediaInfo)
+	return err == nil && vf == ""
+}
+
+func (c *Converter) audioMeetsTarget(mediaInfo *probe.MediaInfo) bool {
+	if c.Cfg.Mute || !mediaInfo.HasAudio() {
+		return false
+	}
+	a := mediaInfo.Audio[0]
+
+	want := "aac"
+	if container, _ := c.container(); container == "webm" {
+		want = "opus"
+	}
+	return a.Codec == want && a.Channels > 0 && a.Channels <= 2
+}
@@ -0,0 +1,42 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/mt4110/rec-watch/internal/config"
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+func TestPlanStreams(t *testing.T) {
+	h264 := func(w, h int, fps float64) *probe.VideoStream {
+		return &probe.VideoStream{Codec: "h264", Width: w, Height: h, FrameRate: fps, PixFmt: "yuv420p"}
+	}
+	aac := []probe.AudioStream{{Codec: "aac", Channels: 2}}
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+		info *probe.MediaInfo
+		want string
+	}{
+		{"h264 1080p30 aac", config.Config{FPS: 30}, &probe.MediaInfo{Video: h264(1920, 1080, 30), Audio: aac}, "remux"},
+		{"29.97fps is close enough", config.Config{FPS: 30}, &probe.MediaInfo{Video: h264(1920, 1080, 29.97), Audio: aac}, "remux"},
+		{"60fps needs encode", config.Config{FPS: 30}, &probe.MediaInfo{Video: h264(1920, 1080, 60), Audio: aac}, "copy_audio"},
+		{"retina needs scaling", config.Config{}, &probe.MediaInfo{Video: h264(2880, 1800, 30), Audio: aac}, "copy_audio"},
+		{"pcm audio", config.Config{}, &probe.MediaInfo{Video: h264(1920, 1080, 30), Audio: []probe.AudioStream{{Codec: "pcm_s16le", Channels: 2}}}, "copy_video"},
+		{"hevc target", config.Config{Codec: "libx265"}, &probe.MediaInfo{Video: h264(1920, 1080, 30), Audio: aac}, "copy_audio"},
+		{"mute", config.Config{Mute: true}, &probe.MediaInfo{Video: h264(1920, 1080, 30), Audio: aac}, "copy_video"},
+		{"webm wants opus", config.Config{Codec: "vp9"}, &probe.MediaInfo{Video: &probe.VideoStream{Codec: "vp9", Width: 1920, Height: 1080, PixFmt: "yuv420p"}, Audio: aac}, "copy_video"},
+		{"noRemux", config.Config{NoRemux: true}, &probe.MediaInfo{Video: h264(1920, 1080, 30), Audio: aac}, "encode"},
+		{"unknown input", config.Config{}, nil, "encode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&tt.cfg)
+			if got := c.planStreams(tt.info).mode(); got != tt.want {
+				t.Errorf("planStreams() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
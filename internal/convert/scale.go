@@ -0,0 +1,177 @@
+package convert
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+// resolution is the target box for scaling. 0 on an axis means "unconstrained".
+// Both 0 = keep the source size.
+type resolution struct {
+	W, H int
+}
+
+var resolutionPresets = map[string]resolution{
+	"source":   {0, 0},
+	"720p":     {1280, 720},
+	"1080p":    {1920, 1080},
+	"1440p":    {2560, 1440},
+	"2160p":    {3840, 2160},
+	"4k":       {3840, 2160},
+	"vertical": {1080, 1920},
+}
+
+// parseResolution parses config/flag values:
+//
+//	"1080p", "720p", "1440p", "4k", "vertical", "source"
+//	"1920x1080" (canvas), "1280x" (max width only), "x720" (max height only)
+func parseResolution(s string) (resolution, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return resolutionPresets["1080p"], nil
+	}
+	if r, ok := resolutionPresets[s]; ok {
+		return r, nil
+	}
+
+	ws, hs, ok := strings.Cut(s, "x")
+	if !ok {
+		return resolution{}, fmt.Errorf("解像度の指定が不正です: %s", s)
+	}
+	var r resolution
+	var err error
+	if ws != "" {
+		if r.W, err = strconv.Atoi(ws); err != nil || r.W <= 0 {
+			return resolution{}, fmt.Errorf("解像度の指定が不正です: %s", s)
+		}
+	}
+	if hs != "" {
+		if r.H, err = strconv.Atoi(hs); err != nil || r.H <= 0 {
+			return resolution{}, fmt.Errorf("解像度の指定が不正です: %s", s)
+		}
+	}
+	if r.W == 0 && r.H == 0 {
+		return resolution{}, fmt.Errorf("解像度の指定が不正です: %s", s)
+	}
+	return r, nil
+}
+
+// isCanvas reports whether both axes are fixed, i.e. padding is meaningful.
+func (r resolution) isCanvas() bool {
+	return r.W > 0 && r.H > 0
+}
+
+// scaleFilter builds the -vf chain (scale + optional pad) for a source of srcW x srcH.
+//
+//   - The source is fitted into the target box keeping its aspect ratio.
+//   - Small sources are never enlarged unless upscale is set.
+//   - With pad, the result is letterboxed to the target aspect ratio. A source that
+//     already fills the box ends up exactly on the canvas (e.g. 1920x1080); a smaller,
+//     non-upscaled source gets a canvas of the same aspect around its own size.
+//
+// Returns "" when no filter is needed.
+func scaleFilter(target resolution, srcW, srcH int, pad, upscale bool) string {
+	if srcW <= 0 || srcH <= 0 {
+		return ""
+	}
+
+	ratio := 1.0
+	if target.W > 0 || target.H > 0 {
+		ratio = math.Inf(1)
+		if target.W > 0 {
+			ratio = math.Min(ratio, float64(target.W)/float64(srcW))
+		}
+		if target.H > 0 {
+			ratio = math.Min(ratio, float64(target.H)/float64(srcH))
+		}
+		if !upscale && ratio > 1 {
+			ratio = 1
+		}
+	}
+
+	// yuv420p encoders need even dimensions
+	w := evenFloor(int(math.Round(float64(srcW) * ratio)))
+	h := evenFloor(int(math.Round(float64(srcH) * ratio)))
+
+	var filters []string
+	if w != srcW || h != srcH {
+		filters = append(filters, fmt.Sprintf("scale=%d:%d", w, h))
+	}
+
+	if pad && target.isCanvas() {
+		cw, ch := w, h
+		if w*target.H < h*target.W {
+			// narrower than target aspect -> pillarbox
+			cw = evenCeil(int(math.Ceil(float64(h) * float64(target.W) / float64(target.H))))
+		} else {
+			// wider than target aspect -> letterbox
+			ch = evenCeil(int(math.Ceil(float64(w) * float64(target.H) / float64(target.W))))
+		}
+		cw = min(cw, max(target.W, w))
+		ch = min(ch, max(target.H, h))
+		if cw > w || ch > h {
+			filters = append(filters, fmt.Sprintf("pad=%d:%d:(ow-iw)/2:(oh-ih)/2", cw, ch))
+		}
+	}
+
+	return strings.Join(filters, ",")
+}
+
+// scaleFilterExpr is the fallback used when the source size is unknown (e.g. DryRun
+// without ffprobe). ffmpeg evaluates the limits itself.
+func scaleFilterExpr(target resolution, pad, upscale bool) string {
+	if target.W == 0 && target.H == 0 {
+		return ""
+	}
+
+	w, h := "-2", "-2"
+	if target.W > 0 {
+		w = strconv.Itoa(target.W)
+		if !upscale {
+			w = fmt.Sprintf("'min(%d,iw)'", target.W)
+		}
+	}
+	if target.H > 0 {
+		h = strconv.Itoa(target.H)
+		if !upscale {
+			h = fmt.Sprintf("'min(%d,ih)'", target.H)
+		}
+	}
+
+	vf := fmt.Sprintf("scale=%s:%s", w, h)
+	if target.isCanvas() {
+		vf += ":force_original_aspect_ratio=decrease:force_divisible_by=2"
+		if pad {
+			vf += fmt.Sprintf(",pad=%d:%d:(ow-iw)/2:(oh-ih)/2", target.W, target.H)
+		}
+	}
+	return vf
+}
+
+// videoFilter returns the -vf value for the configured resolution ("" = no filter).
+func (c *Converter) videoFilter(mediaInfo *probe.MediaInfo) (string, error) {
+	target, err := parseResolution(c.Cfg.Resolution)
+	if err != nil {
+		return "", err
+	}
+	if mediaInfo == nil || mediaInfo.Video == nil {
+		return scaleFilterExpr(target, !c.Cfg.NoPad, c.Cfg.Upscale), nil
+	}
+	w, h := mediaInfo.Video.DisplaySize()
+	return scaleFilter(target, w, h, !c.Cfg.NoPad, c.Cfg.Upscale), nil
+}
+
+func evenFloor(v int) int {
+	if v < 2 {
+		return 2
+	}
+	return v &^ 1
+}
+
+func evenCeil(v int) int {
+	return v + v%2
+}
@@ -0,0 +1,65 @@
+package convert
+
+import "testing"
+
+func TestParseResolution(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    resolution
+		wantErr bool
+	}{
+		{"", resolution{1920, 1080}, false},
+		{"720p", resolution{1280, 720}, false},
+		{"4K", resolution{3840, 2160}, false},
+		{"vertical", resolution{1080, 1920}, false},
+		{"source", resolution{0, 0}, false},
+		{"1080x1920", resolution{1080, 1920}, false},
+		{"1280x", resolution{1280, 0}, false},
+		{"x720", resolution{0, 720}, false},
+		{"x", resolution{}, true},
+		{"big", resolution{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseResolution(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseResolution(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseResolution(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScaleFilter(t *testing.T) {
+	p1080 := resolution{1920, 1080}
+	tests := []struct {
+		name       string
+		target     resolution
+		srcW, srcH int
+		pad        bool
+		upscale    bool
+		want       string
+	}{
+		{"retina 16:10 to 1080p", p1080, 2880, 1800, true, false, "scale=1728:1080,pad=1920:1080:(ow-iw)/2:(oh-ih)/2"},
+		{"retina 16:10 no pad", p1080, 2880, 1800, false, false, "scale=1728:1080"},
+		{"exact fit", p1080, 1920, 1080, true, false, ""},
+		{"small 16:9 is not upscaled", p1080, 1280, 720, true, false, ""},
+		{"small 16:9 upscaled", p1080, 1280, 720, true, true, "scale=1920:1080"},
+		{"small 4:3 padded to 16:9 at own size", p1080, 1280, 960, true, false, "pad=1708:960:(ow-iw)/2:(oh-ih)/2"},
+		{"vertical source into landscape canvas", p1080, 1080, 1920, true, false, "scale=608:1080,pad=1920:1080:(ow-iw)/2:(oh-ih)/2"},
+		{"max width only", resolution{1280, 0}, 2560, 1600, true, false, "scale=1280:800"},
+		{"source keeps size", resolution{}, 2560, 1600, true, false, ""},
+		{"odd source is made even", resolution{}, 1281, 721, false, false, "scale=1280:720"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scaleFilter(tt.target, tt.srcW, tt.srcH, tt.pad, tt.upscale)
+			if got != tt.want {
+				t.Errorf("scaleFilter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package convert
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// Scheduler limits the ffmpeg processes of all jobs of a Converter (batch files,
+// split chunks and their audio track, watcher jobs) to a fixed number of encode
+// slots, and splits a CPU thread budget between them via -threads.
+//
+// Each encode gets an equal share of the free threads among the slots not in use
+// (about budget/slots, the remainder going to the later ones), so the threads
+// handed out never exceed the budget. An encode only starts while at least one
+// thread is free; with more slots than threads that limits the encodes as well.
+type Scheduler struct {
+	mu      sync.Mutex
+	slots   int // encodes that may run at once
+	running int
+	wake    chan struct{} // closed (and replaced) when a slot may have become free
+	budget  int           // CPU threads for all encodes together
+	free    int           // threads not handed out (negative after a shrinking Resize)
+}
+
+// NewScheduler returns a scheduler with slots encode slots and a budget of
+// threads CPU threads (0 = number of CPUs).
+func NewScheduler(slots, threads int) *Scheduler {
+	s := &Scheduler{wake: make(chan struct{})}
+	s.slots, s.budget = schedulerLimits(slots, threads)
+	s.free = s.budget
+	return s
+}
+
+func schedulerLimits(slots, threads int) (int, int) {
+	if slots < 1 {
+		slots = 1
+	}
+	if threads < 1 {
+		threads = runtime.NumCPU()
+	}
+	return slots, threads
+}
+
+// Slots returns the number of encodes that may run at once.
+func (s *Scheduler) Slots() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.slots
+}
+
+// Resize changes the number of slots and the thread budget (config reload).
+// Running encodes keep their slot and threads; with fewer slots, new encodes
+// wait until enough of them have finished.
+func (s *Scheduler) Resize(slots, threads int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slots, threads = schedulerLimits(slots, threads)
+	s.free += threads - s.budget
+	s.slots, s.budget = slots, threads
+	s.broadcast()
+}
+
+// broadcast wakes all waiting acquires. s.mu must be held.
+func (s *Scheduler) broadcast() {
+	close(s.wake)
+	s.wake = make(chan struct{})
+}
+
+// acquire blocks until an encode slot is free and returns the thread count for
+// the encode and a func that gives slot and threads back.
+func (s *Scheduler) acquire(ctx context.Context) (int, func(), error) {
+	s.mu.Lock()
+	for s.running >= s.slots || s.free < 1 {
+		wake := s.wake
+		s.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		}
+		s.mu.Lock()
+	}
+	// Share the free threads with the slots still unused
+	threads := max(1, s.free/(s.slots-s.running))
+	s.running++
+	s.free -= threads
+	s.mu.Unlock()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.free += threads
+			s.running--
+			s.broadcast()
+			s.mu.Unlock()
+		})
+	}
+	return threads, release, nil
+}
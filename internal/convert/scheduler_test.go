@@ -0,0 +1,129 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedulerThreadShares(t *testing.T) {
+	s := NewScheduler(3, 8)
+	ctx := context.Background()
+
+	// Encodes starting one after another share the budget between the slots
+	var got []int
+	var releases []func()
+	for range 3 {
+		threads, release, err := s.acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, threads)
+		releases = append(releases, release)
+	}
+	if got[0] != 2 || got[1] != 3 || got[2] != 3 {
+		t.Errorf("threads = %v, want [2 3 3]", got)
+	}
+	for _, release := range releases {
+		release()
+		release() // idempotent
+	}
+	if s.free != 8 {
+		t.Errorf("free threads after release = %d, want 8", s.free)
+	}
+}
+
+func TestSchedulerStaysInBudget(t *testing.T) {
+	for _, tt := range []struct{ slots, threads int }{{1, 8}, {2, 8}, {3, 8}, {4, 6}, {8, 8}, {4, 2}, {16, 3}} {
+		s := NewScheduler(tt.slots, tt.threads)
+		total, started := 0, 0
+		for range tt.slots {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			threads, _, err := s.acquire(ctx)
+			cancel()
+			if err != nil {
+				break // no thread left for another encode
+			}
+			if threads < 1 {
+				t.Errorf("%+v: encode got %d threads", tt, threads)
+			}
+			total += threads
+			started++
+		}
+		if total > tt.threads {
+			t.Errorf("%+v: %d threads handed out, budget %d", tt, total, tt.threads)
+		}
+		if want := min(tt.slots, tt.threads); started != want {
+			t.Errorf("%+v: %d encodes started, want %d", tt, started, want)
+		}
+	}
+}
+
+func TestSchedulerLimitsSlots(t *testing.T) {
+	s := NewScheduler(1, 4)
+	_, release, err := s.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := s.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second acquire = %v, want to block until ctx is done", err)
+	}
+
+	got := make(chan int)
+	go func() {
+		threads, release, _ := s.acquire(context.Background())
+		release()
+		got <- threads
+	}()
+	time.Sleep(20 * time.Millisecond)
+	release()
+	select {
+	case threads := <-got:
+		if threads != 4 {
+			t.Errorf("threads = %d, want 4", threads)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting encode did not get the slot")
+	}
+}
+
+func TestSchedulerResize(t *testing.T) {
+	s := NewScheduler(1, 4)
+	_, releaseA, _ := s.acquire(context.Background())
+
+	// Growing lets a waiting encode start right away
+	got := make(chan int)
+	var releaseB func()
+	go func() {
+		threads, release, _ := s.acquire(context.Background())
+		releaseB = release
+		got <- threads
+	}()
+	time.Sleep(20 * time.Millisecond)
+	s.Resize(2, 8)
+	select {
+	case threads := <-got:
+		if threads != 4 {
+			t.Errorf("threads after resize = %d, want the 4 added threads", threads)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting encode did not start after the resize")
+	}
+	defer releaseB()
+
+	// Shrinking below the running encodes blocks new ones until they finish
+	s.Resize(1, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	releaseA()
+	if _, _, err := s.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire over the new limit = %v, want to block", err)
+	}
+	if s.Slots() != 1 {
+		t.Errorf("Slots = %d, want 1", s.Slots())
+	}
+}
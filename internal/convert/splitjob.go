@@ -0,0 +1,296 @@
+package convert
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Split jobs keep their chunks in a work dir under <stateDir>/split/ so that an
+// interrupted or failed encode can be resumed. The dir is removed once the merged
+// output is committed.
+//
+//	<stateDir>/split/<stem>-<id>/
+//	  manifest.json
+//	  source/chunk_000.mp4 ...     stream-copied input chunks
+//	  converted/chunk_000.mp4 ...  encoded chunks (video only)
+//	  audio.mka                    audio track of the whole input
+const manifestFile = "manifest.json"
+
+// Work dirs not touched for this long are removed when a new split job starts
+const staleSplitJobAge = 7 * 24 * time.Hour
+
+// First retry delay of a failed chunk, doubled for every further attempt
+var chunkRetryBackoff = 2 * time.Second
+
+// chunkState is one chunk entry of the manifest.
+type chunkState struct {
+	Index    int    `json:"index"`
+	Source   string `json:"source"`
+	Output   string `json:"output"`
+	Done     bool   `json:"done"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// splitManifest describes a split job. Settings is a fingerprint of everything that
+// affects the encoded chunks; a job is only resumed if input and settings match.
+type splitManifest struct {
+	Input        string       `json:"input"`
+	InputSize    int64        `json:"input_size"`
+	InputModTime time.Time    `json:"input_mod_time"`
+	Settings     string       `json:"settings"`
+	Created      time.Time    `json:"created"`
+	Chunks       []chunkState `json:"chunks"`
+	AudioDone    bool         `json:"audio_done"`
+}
+
+// splitJob is an opened work dir with its manifest.
+type splitJob struct {
+	dir string
+
+	mu       sync.Mutex
+	manifest splitManifest
+}
+
+// chunkSettings is the fingerprint stored in the manifest.
+func (c *Converter) chunkSettings(crf int) string {
+	codecArgs, _ := c.videoCodecArgs(crf)
+	return strings.Join([]string{
+		strings.Join(codecArgs, " "),
+		c.Cfg.Resolution,
+		fmt.Sprintf("pad=%v upscale=%v mute=%v", !c.Cfg.NoPad, c.Cfg.Upscale, c.Cfg.Mute),
+		c.outputExt(),
+		fmt.Sprintf("segment=%d", c.splitSegment()),
+		"audio=separate",
+	}, "|")
+}
+
+// openSplitJob returns the work dir for inPath with the given encode settings.
+// An existing manifest for the same input and settings is loaded for resuming;
+// otherwise the dir is (re)created empty.
+func (c *Converter) openSplitJob(inPath string, crf int) (*splitJob, error) {
+	absIn, err := filepath.Abs(inPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absIn)
+	if err != nil {
+		return nil, err
+	}
+	settings := c.chunkSettings(crf)
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%s", absIn, info.Size(), info.ModTime().UnixNano(), settings)))
+	id := hex.EncodeToString(sum[:])[:16]
+	stem := strings.TrimSuffix(filepath.Base(absIn), filepath.Ext(absIn))
+	root := filepath.Join(c.Cfg.StatePath(), "split")
+	job := &splitJob{dir: filepath.Join(root, stem+"-"+id)}
+
+	cleanStaleSplitJobs(root, job.dir)
+
+	if data, err := os.ReadFile(filepath.Join(job.dir, manifestFile)); err == nil {
+		var m splitManifest
+		if json.Unmarshal(data, &m) == nil && m.Input == absIn && m.InputSize == info.Size() &&
+			m.InputModTime.Equal(info.ModTime()) && m.Settings == settings && chunksExist(m.Chunks) {
+			job.manifest = m
+			return job, nil
+		}
+	}
+
+	// New job (or unusable leftovers)
+	if err := os.RemoveAll(job.dir); err != nil {
+		return nil, err
+	}
+	for _, sub := range []string{"source", "converted"} {
+		if err := os.MkdirAll(filepath.Join(job.dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+	job.manifest = splitManifest{
+		Input:        absIn,
+		InputSize:    info.Size(),
+		InputModTime: info.ModTime(),
+		Settings:     settings,
+		Created:      time.Now(),
+	}
+	return job, nil
+}
+
+// chunksExist reports whether a loaded manifest is usable: it has chunks, every
+// source chunk is still there and every chunk marked done has its output.
+func chunksExist(chunks []chunkState) bool {
+	if len(chunks) == 0 {
+		return false
+	}
+	for _, ch := range chunks {
+		if _, err := os.Stat(ch.Source); err != nil {
+			return false
+		}
+		if ch.Done {
+			if _, err := os.Stat(ch.Output); err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// resumed reports whether the job continues from an earlier run.
+func (j *splitJob) resumed() bool {
+	return len(j.manifest.Chunks) > 0
+}
+
+// setChunks records the freshly split source chunks.
+func (j *splitJob) setChunks(sources []string, ext string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.manifest.Chunks = make([]chunkState, len(sources))
+	for i, src := range sources {
+		name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+		j.manifest.Chunks[i] = chunkState{
+			Index:  i,
+			Source: src,
+			Output: filepath.Join(j.dir, "converted", name+ext),
+		}
+	}
+	return j.saveLocked()
+}
+
+// chunks returns a copy of the chunk list.
+func (j *splitJob) chunks() []chunkState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]chunkState(nil), j.manifest.Chunks...)
+}
+
+// finishAttempt records the outcome of one encode attempt of chunk i.
+func (j *splitJob) finishAttempt(i int, err error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ch := &j.manifest.Chunks[i]
+	ch.Attempts++
+	ch.Done = err == nil
+	ch.Error = ""
+	if err != nil {
+		ch.Error = err.Error()
+	}
+	return j.saveLocked()
+}
+
+// saveLocked writes the manifest atomically. j.mu must be held.
+func (j *splitJob) saveLocked() error {
+	data, err := json.MarshalIndent(j.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(j.dir, manifestFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// audioPath is the separately encoded audio track. Matroska holds any audio codec,
+// so the source track can also be copied as is.
+func (j *splitJob) audioPath() string {
+	return filepath.Join(j.dir, "audio.mka")
+}
+
+// audioDone reports whether the audio track was finished in this or an earlier run.
+func (j *splitJob) audioDone() bool {
+	j.mu.Lock()
+	done := j.manifest.AudioDone
+	j.mu.Unlock()
+	if !done {
+		return false
+	}
+	_, err := os.Stat(j.audioPath())
+	return err == nil
+}
+
+func (j *splitJob) setAudioDone() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.manifest.AudioDone = true
+	return j.saveLocked()
+}
+
+// remove deletes the work dir after the job finished.
+func (j *splitJob) remove() {
+	if err := os.RemoveAll(j.dir); err != nil {
+		log.Printf("⚠️ 作業ディレクトリの削除に失敗: %s: %v", j.dir, err)
+	}
+}
+
+// cleanStaleSplitJobs removes abandoned work dirs (other than keep) under root.
+func cleanStaleSplitJobs(root, keep string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		dir := filepath.Join(root, e.Name())
+		if !e.IsDir() || dir == keep {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(dir, manifestFile))
+		if err != nil {
+			info, err = e.Info()
+		}
+		if err == nil && time.Since(info.ModTime()) > staleSplitJobAge {
+			log.Printf("🧹 古い分割ジョブを削除します: %s", dir)
+			os.RemoveAll(dir)
+		}
+	}
+}
+
+// encodeChunk encodes chunk i of job, retrying failures up to ChunkRetries times
+// with exponential backoff. The chunk is written to a temp file first so that an
+// interrupted encode never looks finished.
+func (c *Converter) encodeChunk(ctx context.Context, job *splitJob, ch chunkState, encode func(ctx context.Context, in, out string) error) error {
+	for attempt := 1; ; attempt++ {
+		tmp := tempOutputPath(ch.Output)
+		err := encode(ctx, ch.Source, tmp)
+		if err != nil {
+			os.Remove(tmp)
+		} else if !c.Cfg.DryRun {
+			err = commitOutput(tmp, ch.Output)
+		}
+		if ctx.Err() != nil {
+			// Cancelled: not a failure of the chunk, keep the manifest as is
+			return ctx.Err()
+		}
+		if saveErr := job.finishAttempt(ch.Index, err); saveErr != nil {
+			log.Printf("⚠️ マニフェストの保存に失敗: %v", saveErr)
+		}
+		if err == nil {
+			return nil
+		}
+		if attempt > c.Cfg.ChunkRetries {
+			return err
+		}
+
+		wait := chunkRetryBackoff << (attempt - 1)
+		log.Printf("⚠️ チャンク %d の変換に失敗しました (%d回目)。%s後に再試行します: %v", ch.Index, attempt, wait, firstLine(err))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// firstLine shortens multi-line ffmpeg errors for log lines.
+func firstLine(err error) string {
+	s, _, _ := strings.Cut(err.Error(), "\n")
+	return s
+}
@@ -0,0 +1,180 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/config"
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+func newSplitJobTest(t *testing.T) (*Converter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.mov")
+	if err := os.WriteFile(in, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := New(&config.Config{
+		Codec:        "libx264",
+		CRF:          22,
+		Preset:       "faster",
+		StateDir:     filepath.Join(dir, "state"),
+		ChunkRetries: 2,
+	})
+	return c, in
+}
+
+// writeSourceChunks creates fake split output in the job's source dir.
+func writeSourceChunks(t *testing.T, job *splitJob, n int) []string {
+	t.Helper()
+	var chunks []string
+	for i := range n {
+		p := filepath.Join(job.dir, "source", fmt.Sprintf("chunk_%03d.mp4", i))
+		if err := os.WriteFile(p, []byte("chunk"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, p)
+	}
+	return chunks
+}
+
+func TestSplitJobResume(t *testing.T) {
+	c, in := newSplitJobTest(t)
+
+	job, err := c.openSplitJob(in, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.resumed() {
+		t.Fatal("new job must not be resumed")
+	}
+	if err := job.setChunks(writeSourceChunks(t, job, 3), ".mp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Chunk 0 finished, chunk 1 failed
+	encode := func(ctx context.Context, in, out string) error { return os.WriteFile(out, []byte("enc"), 0644) }
+	if err := c.encodeChunk(context.Background(), job, job.chunks()[0], encode); err != nil {
+		t.Fatal(err)
+	}
+	if err := job.finishAttempt(1, errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same input and settings: resumed with chunk 0 done
+	again, err := c.openSplitJob(in, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.resumed() || again.dir != job.dir {
+		t.Fatal("expected the job to be resumed")
+	}
+	chunks := again.chunks()
+	if !chunks[0].Done || chunks[1].Done || chunks[1].Error != "boom" || chunks[2].Done {
+		t.Errorf("unexpected chunk states: %+v", chunks)
+	}
+
+	// Different CRF: different job, old chunks not reused
+	other, err := c.openSplitJob(in, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.resumed() || other.dir == job.dir {
+		t.Error("changed settings must start a new job")
+	}
+
+	// Missing output of a done chunk: start over
+	os.Remove(chunks[0].Output)
+	fresh, err := c.openSplitJob(in, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh.resumed() {
+		t.Error("job with missing chunk outputs must start over")
+	}
+}
+
+func TestEncodeChunkRetry(t *testing.T) {
+	defer func(d time.Duration) { chunkRetryBackoff = d }(chunkRetryBackoff)
+	chunkRetryBackoff = time.Millisecond
+
+	c, in := newSplitJobTest(t)
+	job, err := c.openSplitJob(in, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := job.setChunks(writeSourceChunks(t, job, 2), ".mp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fails once, then succeeds
+	calls := 0
+	flaky := func(ctx context.Context, in, out string) error {
+		calls++
+		if calls == 1 {
+			return errors.New("transient")
+		}
+		return os.WriteFile(out, []byte("enc"), 0644)
+	}
+	if err := c.encodeChunk(context.Background(), job, job.chunks()[0], flaky); err != nil {
+		t.Fatalf("expected success after retry, got %v", err)
+	}
+	if ch := job.chunks()[0]; !ch.Done || ch.Attempts != 2 {
+		t.Errorf("unexpected state: %+v", ch)
+	}
+	if _, err := os.Stat(job.chunks()[0].Output); err != nil {
+		t.Errorf("chunk output missing: %v", err)
+	}
+
+	// Always fails: 1 + ChunkRetries attempts
+	calls = 0
+	broken := func(ctx context.Context, in, out string) error {
+		calls++
+		return errors.New("broken")
+	}
+	if err := c.encodeChunk(context.Background(), job, job.chunks()[1], broken); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestConvertSplitDryRun(t *testing.T) {
+	c, in := newSplitJobTest(t)
+	// Any ffmpeg run leaves its arguments in calls
+	calls := filepath.Join(t.TempDir(), "calls")
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c.Cfg.FFmpegBin = ffmpeg
+	c.Cfg.DryRun = true
+	c.Cfg.NameTemplate = "{stem}.{ext}"
+
+	outDir := t.TempDir()
+	info := &probe.MediaInfo{Duration: 1000, Audio: []probe.AudioStream{{Codec: "aac", Channels: 2}}}
+	out, err := c.convertSplit(context.Background(), in, outDir, info, nil)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if out != filepath.Join(outDir, "in.mp4") {
+		t.Errorf("output = %s", out)
+	}
+	if data, err := os.ReadFile(calls); err == nil {
+		t.Errorf("ffmpeg ran during a dry run:\n%s", data)
+	}
+	if entries, _ := os.ReadDir(outDir); len(entries) != 0 {
+		t.Errorf("dry run wrote to the dest dir: %v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(c.Cfg.StateDir, "split")); err == nil {
+		t.Error("dry run created a split work dir")
+	}
+}
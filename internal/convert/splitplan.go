@@ -0,0 +1,104 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/probe"
+	"github.com/mt4110/rec-watch/internal/split"
+)
+
+// Default segment length of split mode (5 minutes)
+const defaultSplitSegmentSec = 300
+
+// splitSegment returns the target chunk length in seconds.
+func (c *Converter) splitSegment() int {
+	if c.Cfg.SplitSegmentSec > 0 {
+		return c.Cfg.SplitSegmentSec
+	}
+	return defaultSplitSegmentSec
+}
+
+// autoSplit reports whether the input exceeds autoSplitDuration / autoSplitSize,
+// so that split mode is used without --parallel-split. GPU encodes are never split.
+func (c *Converter) autoSplit(inPath string, mediaInfo *probe.MediaInfo) bool {
+	if c.Cfg.GPU {
+		return false
+	}
+	if c.Cfg.AutoSplitDuration != "" && mediaInfo != nil {
+		if d, err := time.ParseDuration(c.Cfg.AutoSplitDuration); err == nil && mediaInfo.Duration >= d.Seconds() {
+			log.Printf("ℹ️ 動画の長さが %s 以上のため分割並列モードで変換します: %s", c.Cfg.AutoSplitDuration, filepath.Base(inPath))
+			return true
+		}
+	}
+	if c.Cfg.AutoSplitSize != "" {
+		limit, err := ParseSize(c.Cfg.AutoSplitSize)
+		if info, statErr := os.Stat(inPath); err == nil && statErr == nil && info.Size() >= limit {
+			log.Printf("ℹ️ ファイルサイズが %s 以上のため分割並列モードで変換します: %s", c.Cfg.AutoSplitSize, filepath.Base(inPath))
+			return true
+		}
+	}
+	return false
+}
+
+// splitCuts returns balanced cut points at keyframes of the input, preferring
+// keyframes at scene changes. nil means the keyframes could not be read and
+// fixed-length segments are used instead.
+func (c *Converter) splitCuts(ctx context.Context, inPath string, mediaInfo *probe.MediaInfo) ([]float64, error) {
+	if mediaInfo == nil || mediaInfo.Duration <= 0 {
+		return nil, nil
+	}
+	keyframes, err := c.Prober.Keyframes(ctx, inPath)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("⚠️ キーフレーム位置を取得できませんでした (固定長で分割します): %v", err)
+		return nil, nil
+	}
+
+	// Scene changes are optional: without them the cuts are balanced on keyframes only
+	scenes, err := split.New(c.Cfg.FFmpegBin).SceneChanges(ctx, inPath)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("⚠️ シーンの切り替わりを検出できませんでした (キーフレーム位置だけで分割します): %v", err)
+	}
+
+	cuts := split.BalancedCuts(keyframes, scenes, mediaInfo.Duration, float64(c.splitSegment()))
+	if len(cuts) > 0 {
+		onScene := 0
+		for _, cut := range cuts {
+			if slices.ContainsFunc(scenes, func(t float64) bool { return math.Abs(t-cut) < 0.05 }) {
+				onScene++
+			}
+		}
+		log.Printf("✂️ キーフレーム位置で %d 分割 (平均 %.0f秒, シーンの切り替わり %d箇所)", len(cuts)+1, mediaInfo.Duration/float64(len(cuts)+1), onScene)
+	}
+	return cuts, nil
+}
+
+// validateSplit is part of Validate.
+func (c *Converter) validateSplit() error {
+	if c.Cfg.SplitSegmentSec < 0 || (c.Cfg.SplitSegmentSec > 0 && c.Cfg.SplitSegmentSec < 10) {
+		return fmt.Errorf("splitSegmentSec は10秒以上を指定してください: %d", c.Cfg.SplitSegmentSec)
+	}
+	if c.Cfg.AutoSplitDuration != "" {
+		if d, err := time.ParseDuration(c.Cfg.AutoSplitDuration); err != nil || d <= 0 {
+			return fmt.Errorf("autoSplitDuration の指定が不正です (例: 30m, 1h): %s", c.Cfg.AutoSplitDuration)
+		}
+	}
+	if c.Cfg.AutoSplitSize != "" {
+		if _, err := ParseSize(c.Cfg.AutoSplitSize); err != nil {
+			return fmt.Errorf("autoSplitSize: %w", err)
+		}
+	}
+	return nil
+}
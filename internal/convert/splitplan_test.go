@@ -0,0 +1,64 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mt4110/rec-watch/internal/config"
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+func TestAutoSplit(t *testing.T) {
+	in := filepath.Join(t.TempDir(), "in.mov")
+	if err := os.WriteFile(in, make([]byte, 2000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	long := &probe.MediaInfo{Duration: 3600}
+	short := &probe.MediaInfo{Duration: 60}
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+		info *probe.MediaInfo
+		want bool
+	}{
+		{"no thresholds", config.Config{}, long, false},
+		{"duration above", config.Config{AutoSplitDuration: "30m"}, long, true},
+		{"duration below", config.Config{AutoSplitDuration: "30m"}, short, false},
+		{"size above", config.Config{AutoSplitSize: "1KB"}, short, true},
+		{"size below", config.Config{AutoSplitSize: "1MB"}, short, false},
+		{"gpu never splits", config.Config{AutoSplitDuration: "30m", GPU: true}, long, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&tt.cfg)
+			if got := c.autoSplit(in, tt.info); got != tt.want {
+				t.Errorf("autoSplit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSplit(t *testing.T) {
+	valid := []config.Config{
+		{},
+		{SplitSegmentSec: 120, AutoSplitDuration: "1h30m", AutoSplitSize: "2GB"},
+	}
+	for _, cfg := range valid {
+		if err := New(&cfg).validateSplit(); err != nil {
+			t.Errorf("validateSplit(%+v) = %v", cfg, err)
+		}
+	}
+
+	invalid := []config.Config{
+		{SplitSegmentSec: 5},
+		{AutoSplitDuration: "30"},
+		{AutoSplitSize: "huge"},
+	}
+	for _, cfg := range invalid {
+		if err := New(&cfg).validateSplit(); err == nil {
+			t.Errorf("validateSplit(%+v) should fail", cfg)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+// ParseSize parses sizes such as "25MB", "100M", "1.5GB", "500KB" or plain bytes.
+// Units are decimal (1MB = 1,000,000 bytes) so that the result always stays under
+// limits that are advertised in either MB or MiB.
+func ParseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(s, "B")
+
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1e3
+	case strings.HasSuffix(s, "M"):
+		mult = 1e6
+	case strings.HasSuffix(s, "G"):
+		mult = 1e9
+	}
+	s = strings.TrimRight(s, "KMG")
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("サイズの指定が不正です: %s", size)
+	}
+	return int64(v * mult), nil
+}
+
+// Share of the size budget kept free for container overhead and rate control error
+const targetSizeMargin = 0.04
+
+// Minimum bits per pixel per frame that still gives watchable results
+// for screen recordings (mostly static content)
+var minBitsPerPixel = map[string]float64{
+	"h264": 0.04,
+	"hevc": 0.025,
+}
+
+// Below this the encode is refused instead of producing unusable output
+const minVideoKbps = 32
+
+// Downscale steps relative to the configured resolution (1080 -> 720 -> 540 -> 480 -> 360)
+var targetSizeScales = []float64{1, 2.0 / 3, 1.0 / 2, 4.0 / 9, 1.0 / 3}
+
+// sizePlan is the encode setting chosen to hit a target size.
+type sizePlan struct {
+	videoKbps int
+	audioKbps int
+	target    resolution // scaling box passed to scaleFilter
+	width     int        // resulting output size (before pad)
+	height    int
+	fps       int // 0 = keep source frame rate
+	degraded  bool
+}
+
+// planTargetSize computes bitrates for sizeBytes over duration seconds and, if the
+// bitrate is too low for the configured size/frame rate, steps down frame rate
+// (-> 24 fps) and resolution until the bits per pixel are acceptable.
+func planTargetSize(sizeBytes int64, duration float64, srcW, srcH int, srcFPS float64, base resolution, cfgFPS int, mute bool, family string) (sizePlan, error) {
+	if duration <= 0 {
+		return sizePlan{}, fmt.Errorf("動画の長さが不明なため目標サイズを計算できません")
+	}
+
+	totalKbps := int(float64(sizeBytes) * 8 * (1 - targetSizeMargin) / duration / 1000)
+
+	audioKbps := 0
+	if !mute {
+		switch {
+		case totalKbps >= 1000:
+			audioKbps = 128
+		case totalKbps >= 400:
+			audioKbps = 96
+		default:
+			audioKbps = 64
+		}
+	}
+	videoKbps := totalKbps - audioKbps
+	if videoKbps < minVideoKbps {
+		return sizePlan{}, fmt.Errorf("目標サイズが小さすぎます (映像 %d kb/s しか確保できません)", videoKbps)
+	}
+
+	if base.W == 0 && base.H == 0 {
+		base = resolution{srcW, srcH}
+	}
+	fps := cfgFPS
+	if fps == 0 {
+		fps = int(math.Round(srcFPS))
+	}
+	if fps <= 0 {
+		fps = 30
+	}
+	fpsSteps := []int{fps}
+	if fps > 24 {
+		fpsSteps = append(fpsSteps, 24)
+	}
+
+	bpp := minBitsPerPixel[family]
+	if bpp == 0 {
+		bpp = minBitsPerPixel["h264"]
+	}
+
+	plan := sizePlan{videoKbps: videoKbps, audioKbps: audioKbps}
+	for i, scale := range targetSizeScales {
+		box := resolution{int(float64(base.W) * scale), int(float64(base.H) * scale)}
+		w, h := fitSize(box, srcW, srcH)
+		for j, f := range fpsSteps {
+			plan.target, plan.width, plan.height, plan.fps = box, w, h, f
+			plan.degraded = i > 0 || j > 0
+			if float64(videoKbps)*1000/float64(w*h*f) >= bpp {
+				if f == fps && cfgFPS == 0 {
+					plan.fps = 0
+				}
+				return plan, nil
+			}
+		}
+	}
+
+	// Cap is not reachable at good quality: last resort is the smallest size at 15 fps
+	plan.fps = min(fps, 15)
+	plan.degraded = true
+	return plan, nil
+}
+
+// fitSize returns the output size of srcW x srcH fitted into box without upscaling.
+func fitSize(box resolution, srcW, srcH int) (int, int) {
+	ratio := 1.0
+	if box.W > 0 {
+		ratio = math.Min(ratio, float64(box.W)/float64(srcW))
+	}
+	if box.H > 0 {
+		ratio = math.Min(ratio, float64(box.H)/float64(srcH))
+	}
+	return evenFloor(int(math.Round(float64(srcW) * ratio))), evenFloor(int(math.Round(float64(srcH) * ratio)))
+}
+
+// twoPassArgs returns the video encoder arguments for one pass of an ABR encode.
+func (c *Converter) twoPassArgs(codec string, kbps, pass int, logPrefix string) []string {
+	args := []string{"-c:v", codec, "-preset", c.Cfg.Preset, "-b:v", fmt.Sprintf("%dk", kbps)}
+	if codec == "libx265" {
+		// libx265 takes its pass settings through x265-params
+		return append(args, "-x265-params", fmt.Sprintf("pass=%d:stats=%s.log", pass, logPrefix))
+	}
+	return append(args, "-pass", strconv.Itoa(pass), "-passlogfile", logPrefix)
+}
+
+// convertTargetSize encodes inPath in two passes so that the output fits TargetSize.
+func (c *Converter) convertTargetSize(ctx context.Context, inPath string, outDir string, mediaInfo *probe.MediaInfo) (string, error) {
+	sizeBytes, err := ParseSize(c.Cfg.TargetSize)
+	if err != nil {
+		return "", err
+	}
+	if mediaInfo == nil || mediaInfo.Video == nil {
+		return "", fmt.Errorf("目標サイズ指定には ffprobe による動画の長さが必要です")
+	}
+
+	codec, spec, err := c.videoCodec()
+	if err != nil {
+		return "", err
+	}
+	if c.Cfg.GPU {
+		log.Printf("ℹ️ 目標サイズ指定の2パス変換はCPUで行います")
+	}
+
+	base, err := parseResolution(c.Cfg.Resolution)
+	if err != nil {
+		return "", err
+	}
+	srcW, srcH := mediaInfo.Video.DisplaySize()
+	mute := c.Cfg.Mute || !mediaInfo.HasAudio()
+	plan, err := planTargetSize(sizeBytes, mediaInfo.Duration, srcW, srcH, mediaInfo.Video.FrameRate, base, c.Cfg.FPS, mute, spec.family)
+	if err != nil {
+		return "", err
+	}
+
+	fpsLabel := "source"
+	if plan.fps > 0 {
+		fpsLabel = strconv.Itoa(plan.fps)
+	}
+	log.Printf("🎯 目標サイズ %s: 映像 %d kb/s / 音声 %d kb/s / %dx%d / %sfps", formatSizeMB(sizeBytes), plan.videoKbps, plan.audioKbps, plan.width, plan.height, fpsLabel)
+	if plan.degraded {
+		log.Printf("⚠️ 目標サイズに収めるため解像度またはフレームレートを下げます")
+	}
+
+	outPath, release, err := c.reserveOutput(inPath, outDir)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	var filterArgs []string
+	vf := scaleFilter(plan.target, srcW, srcH, !c.Cfg.NoPad, false)
+	if vf != "" {
+		filterArgs = append(filterArgs, "-vf", vf)
+	}
+	if plan.fps > 0 {
+		filterArgs = append(filterArgs, "-r", strconv.Itoa(plan.fps))
+	}
+
+	passDir, err := os.MkdirTemp("", "rec-watch-2pass-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(passDir)
+	logPrefix := filepath.Join(passDir, "pass")
+
+	// Pass 1: analysis only, no output file
+	pass1 := []string{"-y", "-i", inPath}
+	pass1 = append(pass1, c.twoPassArgs(codec, plan.videoKbps, 1, logPrefix)...)
+	pass1 = append(pass1, filterArgs...)
+	pass1 = append(pass1, "-an", "-f", "null", os.DevNull)
+
+	// Pass 2: real encode into the hidden temp file
+	tmpPath := tempOutputPath(outPath)
+	pass2 := []string{"-i", inPath}
+	pass2 = append(pass2, c.twoPassArgs(codec, plan.videoKbps, 2, logPrefix)...)
+	pass2 = append(pass2, filterArgs...)
+	pass2 = append(pass2, c.containerArgs()...)
+	if mute {
+		pass2 = append(pass2, "-an")
+	} else {
+		pass2 = append(pass2, "-acodec", "aac", "-b:a", fmt.Sprintf("%dk", plan.audioKbps), "-ac", "2")
+	}
+	pass2 = append(pass2, "-y", tmpPath)
+
+	log.Printf("▶ 変換 (target_size): %s -> %s", inPath, outPath)
+	startTime := time.Now()
+
+	if c.Cfg.DryRun {
+		log.Printf("[DryRun] Pass 1: %s %v", c.ffmpegPath(), pass1)
+		log.Printf("[DryRun] Pass 2: %s %v", c.ffmpegPath(), pass2)
+		return outPath, nil
+	}
+
+	// Both passes count towards the progress (part 0 + part 1 = 2x duration)
+	progress := c.newProgress(inPath, mediaInfo)
+	progress.duration *= 2
+
+	if err := c.runFFmpeg(ctx, pass1, progress.part(0)); err != nil {
+		return "", fmt.Errorf("pass 1 failed: %w", err)
+	}
+	progress.endPart(0)
+	if err := c.runFFmpeg(ctx, pass2, progress.part(1)); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("pass 2 failed: %w", err)
+	}
+	if err := c.verifyOutput(ctx, tmpPath, mediaInfo); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := commitOutput(tmpPath, outPath); err != nil {
+		return "", err
+	}
+	progress.finish()
+
+	if info, err := os.Stat(outPath); err == nil && info.Size() > sizeBytes {
+		log.Printf("⚠️ 出力が目標サイズを超えました: %s > %s", formatSizeMB(info.Size()), formatSizeMB(sizeBytes))
+	}
+
+	elapsed := time.Since(startTime)
+	quality := c.qualityReport(ctx, inPath, outPath, qualityRefFilter(vf, plan.fps), mediaInfo)
+	c.logResult(resultRecord{
+		Input:       inPath,
+		Output:      outPath,
+		Mode:        "target_size",
+		DurationSec: elapsed.Seconds(),
+		Quality:     quality,
+	}, mediaInfo)
+
+	if !c.Cfg.NoTrash && !c.Cfg.DryRun {
+		if err := moveToTrash(inPath); err != nil {
+			log.Printf("🗑 ゴミ箱への移動に失敗: %s -> %v", inPath, err)
+		}
+	}
+
+	return outPath, nil
+}
+
+// validateTargetSize is part of Validate.
+func (c *Converter) validateTargetSize() error {
+	if c.Cfg.TargetSize == "" {
+		return nil
+	}
+	if _, err := ParseSize(c.Cfg.TargetSize); err != nil {
+		return err
+	}
+	codec, _, err := c.videoCodec()
+	if err != nil {
+		return err
+	}
+	if codec != "libx264" && codec != "libx265" {
+		return fmt.Errorf("目標サイズ指定は libx264 / libx265 のみ対応しています: %s", codec)
+	}
+	return nil
+}
+
+func formatSizeMB(b int64) string {
+	return fmt.Sprintf("%.1fMB", float64(b)/1e6)
+}
@@ -0,0 +1,59 @@
+package convert
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"25MB":   25_000_000,
+		"100m":   100_000_000,
+		"1.5GB":  1_500_000_000,
+		"500KB":  500_000,
+		"123456": 123456,
+	}
+	for in, want := range tests {
+		got, err := ParseSize(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "MB", "-5MB", "big"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) should fail", in)
+		}
+	}
+}
+
+func TestPlanTargetSize(t *testing.T) {
+	p1080 := resolution{1920, 1080}
+
+	// 10 minutes into 300MB: ~3.8 Mb/s fits 1080p at 30fps
+	plan, err := planTargetSize(300_000_000, 600, 1920, 1080, 30, p1080, 30, false, "h264")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.degraded || plan.width != 1920 || plan.audioKbps != 128 {
+		t.Errorf("expected full 1080p with 128k audio, got %+v", plan)
+	}
+	if plan.videoKbps+plan.audioKbps > 300_000_000*8/600/1000 {
+		t.Errorf("bitrate exceeds the budget: %+v", plan)
+	}
+
+	// 10 minutes into 25MB: must step down resolution
+	plan, err = planTargetSize(25_000_000, 600, 1920, 1080, 30, p1080, 30, false, "h264")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.degraded || plan.width >= 1920 || plan.audioKbps != 64 {
+		t.Errorf("expected downscaled plan, got %+v", plan)
+	}
+
+	// Hopeless: 3 hours into 1MB
+	if _, err := planTargetSize(1_000_000, 3*3600, 1920, 1080, 30, p1080, 30, false, "h264"); err == nil {
+		t.Error("expected error for unreachable target")
+	}
+
+	// Unknown duration
+	if _, err := planTargetSize(25_000_000, 0, 1920, 1080, 30, p1080, 30, false, "h264"); err == nil {
+		t.Error("expected error for unknown duration")
+	}
+}
@@ -0,0 +1,80 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+// ErrVerifyFailed is returned when the converted file does not match the input.
+// The source is never trashed in that case.
+var ErrVerifyFailed = errors.New("出力の検証に失敗しました")
+
+// Allowed difference between input and output duration when verifyTolerance is not set
+const defaultVerifyTolerance = 2.0
+
+// verifyOutput checks the freshly written (still temporary) output at path against
+// the probed input before it is committed and the source is moved to the trash:
+//
+//   - the output can be probed and has a video stream
+//   - it has an audio stream if the input had one (unless muted)
+//   - its duration matches the input within verifyTolerance seconds
+//   - with verifyDecode, ffmpeg can decode the whole file without errors
+func (c *Converter) verifyOutput(ctx context.Context, path string, src *probe.MediaInfo) error {
+	if c.Cfg.NoVerify || c.Cfg.DryRun || src == nil {
+		return nil
+	}
+
+	out, err := c.Prober.Probe(ctx, path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerifyFailed, err)
+	}
+	wantAudio := src.HasAudio() && !c.Cfg.Mute
+	if err := checkOutput(src, out, wantAudio, c.verifyTolerance()); err != nil {
+		return fmt.Errorf("%w: %v", ErrVerifyFailed, err)
+	}
+
+	if c.Cfg.VerifyDecode {
+		log.Printf("🔍 出力をデコードして検証中: %s", filepath.Base(path))
+		args := []string{"-v", "error", "-xerror", "-i", path, "-f", "null", os.DevNull}
+		if err := c.runFFmpeg(ctx, args, nil); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			return fmt.Errorf("%w: デコードエラー: %v", ErrVerifyFailed, err)
+		}
+	}
+	return nil
+}
+
+// checkOutput compares the probe results of input and output.
+func checkOutput(src, out *probe.MediaInfo, wantAudio bool, tolerance float64) error {
+	if !out.HasVideo() {
+		return fmt.Errorf("映像ストリームがありません")
+	}
+	if wantAudio && !out.HasAudio() {
+		return fmt.Errorf("音声ストリームがありません")
+	}
+	if src.Duration > 0 {
+		if out.Duration <= 0 {
+			return fmt.Errorf("出力の長さを取得できません")
+		}
+		if diff := math.Abs(out.Duration - src.Duration); diff > tolerance {
+			return fmt.Errorf("長さが一致しません (入力 %.2f秒 / 出力 %.2f秒)", src.Duration, out.Duration)
+		}
+	}
+	return nil
+}
+
+func (c *Converter) verifyTolerance() float64 {
+	if c.Cfg.VerifyTolerance > 0 {
+		return c.Cfg.VerifyTolerance
+	}
+	return defaultVerifyTolerance
+}
@@ -0,0 +1,37 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/mt4110/rec-watch/internal/probe"
+)
+
+func TestCheckOutput(t *testing.T) {
+	src := &probe.MediaInfo{
+		Duration: 600,
+		Video:    &probe.VideoStream{Codec: "h264"},
+		Audio:    []probe.AudioStream{{Codec: "aac"}},
+	}
+
+	tests := []struct {
+		name      string
+		out       *probe.MediaInfo
+		wantAudio bool
+		wantErr   bool
+	}{
+		{"ok", &probe.MediaInfo{Duration: 599.5, Video: &probe.VideoStream{}, Audio: []probe.AudioStream{{}}}, true, false},
+		{"no video", &probe.MediaInfo{Duration: 600, Audio: []probe.AudioStream{{}}}, true, true},
+		{"missing audio", &probe.MediaInfo{Duration: 600, Video: &probe.VideoStream{}}, true, true},
+		{"muted", &probe.MediaInfo{Duration: 600, Video: &probe.VideoStream{}}, false, false},
+		{"truncated", &probe.MediaInfo{Duration: 312, Video: &probe.VideoStream{}, Audio: []probe.AudioStream{{}}}, true, true},
+		{"no duration", &probe.MediaInfo{Video: &probe.VideoStream{}, Audio: []probe.AudioStream{{}}}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOutput(src, tt.out, tt.wantAudio, defaultVerifyTolerance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -22,7 +22,7 @@ func Setup(logFilePath string) {
 		}
 	}
 
-	fmt.Printf("Log file: %s\n", logFilePath)
+	fmt.Fprintf(os.Stderr, "Log file: %s\n", logFilePath)
 
 	// Lumberjack logger for rotation
 	rotator = &lumberjack.Logger{
@@ -0,0 +1,287 @@
+package probe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mt4110/rec-watch/internal/proc"
+)
+
+// MediaInfo is the typed result of a single ffprobe run.
+type MediaInfo struct {
+	Path       string        `json:"path"`
+	FormatName string        `json:"format_name"`
+	Duration   float64       `json:"duration_sec"`
+	Size       int64         `json:"size"`
+	BitRate    int64         `json:"bit_rate"`
+	Video      *VideoStream  `json:"video,omitempty"`
+	Audio      []AudioStream `json:"audio"`
+}
+
+type VideoStream struct {
+	Index     int     `json:"index"`
+	Codec     string  `json:"codec"`
+	Profile   string  `json:"profile,omitempty"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	PixFmt    string  `json:"pix_fmt,omitempty"`
+	FrameRate float64 `json:"frame_rate"`
+	BitRate   int64   `json:"bit_rate,omitempty"`
+	Rotation  int     `json:"rotation,omitempty"`
+}
+
+// DisplaySize returns width/height as shown by players (ffmpeg auto-rotates, so
+// portrait phone recordings stored as 1920x1080 with rotation 90 come out 1080x1920).
+func (v *VideoStream) DisplaySize() (int, int) {
+	if v.Rotation%180 != 0 {
+		return v.Height, v.Width
+	}
+	return v.Width, v.Height
+}
+
+type AudioStream struct {
+	Index      int    `json:"index"`
+	Codec      string `json:"codec"`
+	Channels   int    `json:"channels"`
+	SampleRate int    `json:"sample_rate"`
+	BitRate    int64  `json:"bit_rate,omitempty"`
+	Language   string `json:"language,omitempty"`
+}
+
+// HasVideo reports whether the input contains a real video stream
+// (cover art / attached pictures are not counted).
+func (m *MediaInfo) HasVideo() bool {
+	return m != nil && m.Video != nil
+}
+
+// HasAudio reports whether the input contains at least one audio stream.
+func (m *MediaInfo) HasAudio() bool {
+	return m != nil && len(m.Audio) > 0
+}
+
+// Prober runs ffprobe against media files
+type Prober struct {
+	FFprobeBin string
+}
+
+func New(ffprobeBin string) *Prober {
+	if ffprobeBin == "" {
+		ffprobeBin = "ffprobe"
+	}
+	return &Prober{FFprobeBin: ffprobeBin}
+}
+
+// BinFromFFmpeg guesses the ffprobe binary that ships next to a custom ffmpeg binary.
+// Returns "" (= look up "ffprobe" in PATH) if ffmpegBin is not an explicit path.
+func BinFromFFmpeg(ffmpegBin string) string {
+	if ffmpegBin == "" || filepath.Base(ffmpegBin) == ffmpegBin {
+		return ""
+	}
+	dir := filepath.Dir(ffmpegBin)
+	name := strings.Replace(filepath.Base(ffmpegBin), "ffmpeg", "ffprobe", 1)
+	return filepath.Join(dir, name)
+}
+
+// Probe runs ffprobe once and returns the parsed MediaInfo.
+func (p *Prober) Probe(ctx context.Context, path string) (*MediaInfo, error) {
+	args := []string{
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	}
+
+	out, err := proc.Command(ctx, p.FFprobeBin, args...).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("ffprobe failed: %v\n%s", err, string(ee.Stderr))
+		}
+		return nil, fmt.Errorf("ffprobe failed: %v", err)
+	}
+
+	info, err := Parse(out)
+	if err != nil {
+		return nil, err
+	}
+	info.Path = path
+	return info, nil
+}
+
+// Keyframes returns the presentation times (seconds) of all keyframes of the first
+// video stream, sorted. Only packet headers are read, nothing is decoded.
+func (p *Prober) Keyframes(ctx context.Context, path string) ([]float64, error) {
+	args := []string{
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,flags",
+		"-of", "csv=p=0",
+		path,
+	}
+
+	out, err := proc.Command(ctx, p.FFprobeBin, args...).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("ffprobe failed: %v\n%s", err, string(ee.Stderr))
+		}
+		return nil, fmt.Errorf("ffprobe failed: %v", err)
+	}
+	return ParseKeyframes(out), nil
+}
+
+// ParseKeyframes parses "pts_time,flags" CSV lines (e.g. "12.345000,K__") and
+// returns the sorted times of packets flagged as keyframes.
+func ParseKeyframes(data []byte) []float64 {
+	var times []float64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		pts, flags, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ",")
+		if !ok || !strings.Contains(flags, "K") {
+			continue
+		}
+		t, err := strconv.ParseFloat(pts, 64)
+		if err != nil {
+			continue // N/A
+		}
+		times = append(times, t)
+	}
+	// Packets are in decode order, which differs from presentation order with B-frames
+	sort.Float64s(times)
+	return times
+}
+
+// Raw ffprobe JSON layout (only the fields we use)
+type ffprobeOutput struct {
+	Streams []struct {
+		Index        int               `json:"index"`
+		CodecName    string            `json:"codec_name"`
+		CodecType    string            `json:"codec_type"`
+		Profile      string            `json:"profile"`
+		Width        int               `json:"width"`
+		Height       int               `json:"height"`
+		PixFmt       string            `json:"pix_fmt"`
+		RFrameRate   string            `json:"r_frame_rate"`
+		AvgFrameRate string            `json:"avg_frame_rate"`
+		SampleRate   string            `json:"sample_rate"`
+		Channels     int               `json:"channels"`
+		BitRate      string            `json:"bit_rate"`
+		Duration     string            `json:"duration"`
+		Tags         map[string]string `json:"tags"`
+		Disposition  map[string]int    `json:"disposition"`
+		SideDataList []struct {
+			Rotation int `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		Filename   string `json:"filename"`
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		Size       string `json:"size"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// Parse converts ffprobe's JSON output (-print_format json -show_format -show_streams) into MediaInfo.
+func Parse(data []byte) (*MediaInfo, error) {
+	var raw ffprobeOutput
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("ffprobe output parse failed: %w", err)
+	}
+
+	info := &MediaInfo{
+		Path:       raw.Format.Filename,
+		FormatName: raw.Format.FormatName,
+		Duration:   parseFloat(raw.Format.Duration),
+		Size:       parseInt(raw.Format.Size),
+		BitRate:    parseInt(raw.Format.BitRate),
+		Audio:      []AudioStream{},
+	}
+
+	for _, s := range raw.Streams {
+		switch s.CodecType {
+		case "video":
+			// Skip cover art (mjpeg/png thumbnails embedded in mp4/m4v)
+			if s.Disposition["attached_pic"] == 1 || info.Video != nil {
+				continue
+			}
+			fps := parseRate(s.AvgFrameRate)
+			if fps == 0 {
+				fps = parseRate(s.RFrameRate)
+			}
+			info.Video = &VideoStream{
+				Index:     s.Index,
+				Codec:     s.CodecName,
+				Profile:   s.Profile,
+				Width:     s.Width,
+				Height:    s.Height,
+				PixFmt:    s.PixFmt,
+				FrameRate: fps,
+				BitRate:   parseInt(s.BitRate),
+				Rotation:  int(parseInt(s.Tags["rotate"])),
+			}
+			// Newer ffprobe reports the display matrix rotation in side data
+			for _, sd := range s.SideDataList {
+				if sd.Rotation != 0 {
+					info.Video.Rotation = sd.Rotation
+				}
+			}
+			if info.Duration == 0 {
+				info.Duration = parseFloat(s.Duration)
+			}
+		case "audio":
+			info.Audio = append(info.Audio, AudioStream{
+				Index:      s.Index,
+				Codec:      s.CodecName,
+				Channels:   s.Channels,
+				SampleRate: int(parseInt(s.SampleRate)),
+				BitRate:    parseInt(s.BitRate),
+				Language:   s.Tags["language"],
+			})
+		}
+	}
+
+	return info, nil
+}
+
+// parseRate parses ffprobe rational values such as "30000/1001" or "30/1".
+func parseRate(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		return parseFloat(s)
+	}
+	n, d := parseFloat(num), parseFloat(den)
+	if d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+func parseFloat(s string) float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+func parseInt(s string) int64 {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
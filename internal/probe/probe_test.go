@@ -0,0 +1,124 @@
+package probe
+
+import (
+	"math"
+	"testing"
+)
+
+const sampleJSON = `{
+  "streams": [
+    {
+      "index": 0,
+      "codec_name": "h264",
+      "codec_type": "video",
+      "profile": "High",
+      "width": 2880,
+      "height": 1800,
+      "pix_fmt": "yuv420p",
+      "r_frame_rate": "60/1",
+      "avg_frame_rate": "30000/1001",
+      "bit_rate": "8000000"
+    },
+    {
+      "index": 1,
+      "codec_name": "aac",
+      "codec_type": "audio",
+      "sample_rate": "48000",
+      "channels": 2,
+      "bit_rate": "128000",
+      "tags": {"language": "jpn"}
+    },
+    {
+      "index": 2,
+      "codec_name": "mjpeg",
+      "codec_type": "video",
+      "width": 320,
+      "height": 240,
+      "disposition": {"attached_pic": 1}
+    }
+  ],
+  "format": {
+    "filename": "/tmp/in.mov",
+    "format_name": "mov,mp4,m4a,3gp,3g2,mj2",
+    "duration": "125.500000",
+    "size": "104857600",
+    "bit_rate": "6684354"
+  }
+}`
+
+func TestParse(t *testing.T) {
+	info, err := Parse([]byte(sampleJSON))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if info.Duration != 125.5 {
+		t.Errorf("expected duration 125.5, got %v", info.Duration)
+	}
+	if info.Size != 104857600 {
+		t.Errorf("expected size 104857600, got %d", info.Size)
+	}
+	if !info.HasVideo() {
+		t.Fatal("expected video stream")
+	}
+	if info.Video.Codec != "h264" || info.Video.Width != 2880 || info.Video.Height != 1800 {
+		t.Errorf("unexpected video stream: %+v", info.Video)
+	}
+	if math.Abs(info.Video.FrameRate-29.97) > 0.01 {
+		t.Errorf("expected ~29.97 fps, got %v", info.Video.FrameRate)
+	}
+	if len(info.Audio) != 1 || info.Audio[0].Channels != 2 || info.Audio[0].Language != "jpn" {
+		t.Errorf("unexpected audio streams: %+v", info.Audio)
+	}
+}
+
+func TestParse_AudioOnly(t *testing.T) {
+	info, err := Parse([]byte(`{"streams":[{"index":0,"codec_type":"audio","codec_name":"aac"}],"format":{"duration":"3.0"}}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if info.HasVideo() {
+		t.Error("expected no video stream")
+	}
+	if !info.HasAudio() {
+		t.Error("expected audio stream")
+	}
+}
+
+func TestDisplaySize_Rotated(t *testing.T) {
+	info, err := Parse([]byte(`{"streams":[{"index":0,"codec_type":"video","codec_name":"hevc","width":1920,"height":1080,"side_data_list":[{"rotation":-90}]}],"format":{}}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	w, h := info.Video.DisplaySize()
+	if w != 1080 || h != 1920 {
+		t.Errorf("expected 1080x1920, got %dx%d", w, h)
+	}
+}
+
+func TestBinFromFFmpeg(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"ffmpeg":                   "",
+		"/opt/homebrew/bin/ffmpeg": "/opt/homebrew/bin/ffprobe",
+	}
+	for in, want := range tests {
+		if got := BinFromFFmpeg(in); got != want {
+			t.Errorf("BinFromFFmpeg(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseKeyframes(t *testing.T) {
+	data := []byte("0.000000,K__\n0.033333,___\nN/A,K__\n4.000000,K_\n2.000000,K__\n2.033333,__D\n")
+	got := ParseKeyframes(data)
+	want := []float64{0, 2, 4}
+	if len(got) != len(want) {
+		t.Fatalf("ParseKeyframes() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ParseKeyframes()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
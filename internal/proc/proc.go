@@ -0,0 +1,20 @@
+package proc
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// How long Wait keeps waiting for stdout/stderr after the process was killed
+const waitDelay = 5 * time.Second
+
+// Command is exec.CommandContext for long running helpers (ffmpeg, ffprobe).
+// When ctx is cancelled the whole process tree is killed, not only the direct child,
+// so that Ctrl+C never leaves orphaned encoders behind.
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package proc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCommand_CancelKillsProcessGroup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The grandchild keeps stdout open; without killing the group Wait would hang
+	cmd := Command(ctx, "sh", "-c", "sleep 30 & wait")
+	if _, err := cmd.StdoutPipe(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := cmd.Wait(); err == nil {
+		t.Fatal("expected error after cancel")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("process group was not killed promptly (%v)", elapsed)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package proc
+
+import "os/exec"
+
+// setProcessGroup is a no-op on Windows; exec.CommandContext already kills the process.
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,189 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// How long a health check may take before the worker counts as unreachable
+const healthTimeout = 3 * time.Second
+
+// DefaultIdleTimeout is how long an encode may go without upload progress, a
+// heartbeat or download progress before the worker counts as lost.
+const DefaultIdleTimeout = time.Minute
+
+var errStalled = errors.New("ワーカーからの応答が途絶えました")
+
+// Client sends chunks to workers.
+type Client struct {
+	Token string
+	HTTP  *http.Client
+	// IdleTimeout aborts an encode once the worker has been silent this long
+	// (see Server.Heartbeat)
+	IdleTimeout time.Duration
+}
+
+// NewClient returns a client authenticating with token ("" = none).
+func NewClient(token string) *Client {
+	return &Client{Token: token, HTTP: &http.Client{}, IdleTimeout: DefaultIdleTimeout}
+}
+
+func (c *Client) newRequest(ctx context.Context, method, worker, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(worker, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return req, nil
+}
+
+// Health asks a worker for its slot count.
+func (c *Client) Health(ctx context.Context, worker string) (Health, error) {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, http.MethodGet, worker, healthPath, nil)
+	if err != nil {
+		return Health{}, err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return Health{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Health{}, fmt.Errorf("%w: %s", ErrUnavailable, resp.Status)
+	}
+	var h Health
+	if err := json.NewDecoder(resp.Body).Decode(&h); err != nil {
+		return Health{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return h, nil
+}
+
+// Encode uploads inPath to worker, has it encoded with args (see ValidateArgs)
+// and writes the result to outPath.
+//
+// Errors wrapping ErrUnavailable mean the worker is lost, busy or refused the job;
+// any other error is an encode failure of the chunk itself.
+func (c *Client) Encode(ctx context.Context, worker, inPath, outPath string, args []string) error {
+	header, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	// A worker that dies without closing the connection would otherwise keep the
+	// chunk waiting forever: every sign of life pushes the deadline back.
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	idle := c.IdleTimeout
+	if idle <= 0 {
+		idle = DefaultIdleTimeout
+	}
+	watchdog := time.AfterFunc(idle, func() { cancel(errStalled) })
+	defer watchdog.Stop()
+	touch := func() { watchdog.Reset(idle) }
+	reqCtx = httptrace.WithClientTrace(reqCtx, &httptrace.ClientTrace{
+		Got1xxResponse: func(int, textproto.MIMEHeader) error {
+			touch()
+			return nil
+		},
+	})
+
+	query := url.Values{"in": {filepath.Ext(inPath)}, "out": {filepath.Ext(outPath)}}
+	req, err := c.newRequest(reqCtx, http.MethodPost, worker, encodePath+"?"+query.Encode(), &activityReader{r: in, touch: touch})
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(argsHeader, string(header))
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: %v", ErrUnavailable, stallCause(reqCtx, err))
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusServiceUnavailable:
+		return ErrBusy
+	case resp.StatusCode == http.StatusInternalServerError:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 8192))
+		return fmt.Errorf("%s: %s", worker, strings.TrimSpace(string(msg)))
+	default:
+		// 400/401/404: wrong token or incompatible worker version
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%w: %s: %s", ErrUnavailable, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, &activityReader{r: resp.Body, touch: touch})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && resp.ContentLength >= 0 && n != resp.ContentLength {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		os.Remove(outPath)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Connection dropped while downloading
+		return fmt.Errorf("%w: %v", ErrUnavailable, stallCause(reqCtx, err))
+	}
+	return nil
+}
+
+// stallCause replaces the bare "context canceled" of a request aborted by the
+// idle watchdog with errStalled.
+func stallCause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, errStalled) {
+		return cause
+	}
+	return err
+}
+
+// activityReader calls touch whenever data flows.
+type activityReader struct {
+	r     io.Reader
+	touch func()
+}
+
+func (a *activityReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.touch()
+	}
+	return n, err
+}
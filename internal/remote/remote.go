@@ -0,0 +1,137 @@
+// Package remote encodes split-mode chunks on other machines.
+//
+// A worker (rec-watch worker) serves two endpoints:
+//
+//	GET  /v1/health                      {"slots": 4, "busy": 1}
+//	POST /v1/encode?in=.mp4&out=.mp4     body: source chunk, response: encoded chunk
+//
+// The encoder options travel in the X-Rec-Watch-Args header as a JSON array and
+// are checked against an allowlist, so a client can only choose how a chunk is
+// encoded, never which files ffmpeg reads or writes.
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	healthPath = "/v1/health"
+	encodePath = "/v1/encode"
+	argsHeader = "X-Rec-Watch-Args"
+)
+
+// DefaultListen is the default address of rec-watch worker
+const DefaultListen = "127.0.0.1:8765"
+
+var (
+	// ErrUnavailable means the worker could not be reached or refused the request;
+	// the chunk should be encoded elsewhere.
+	ErrUnavailable = errors.New("ワーカーを利用できません")
+	// ErrBusy means all encode slots of the worker are in use.
+	ErrBusy = fmt.Errorf("%w: 空きスロットがありません", ErrUnavailable)
+)
+
+// Health is the response of /v1/health.
+type Health struct {
+	Slots int `json:"slots"`
+	Busy  int `json:"busy"`
+}
+
+// Encoders a worker runs
+var encoders = []string{
+	"libx264", "libx265", "libsvtav1", "libaom-av1", "libvpx-vp9",
+	"h264_videotoolbox", "hevc_videotoolbox",
+}
+
+// Options a client may pass, and whether they take a value
+var allowedOptions = map[string]bool{
+	"-c:v": true, "-vcodec": true,
+	"-preset": true, "-crf": true, "-b:v": true, "-q:v": true,
+	"-cpu-used": true, "-row-mt": true, "-deadline": true,
+	"-r": true, "-threads": true, "-pix_fmt": true,
+	"-movflags": true, "-tag:v": true,
+	"-vf": true,
+	"-an": false,
+}
+
+// Filters allowed in -vf (none of them can open files)
+var allowedFilters = []string{"scale", "pad", "fps", "format", "setsar"}
+
+// Containers a chunk may be read from / written to
+var containers = []string{".mp4", ".mkv", ".webm", ".mov", ".ts"}
+
+var (
+	plainValueRe  = regexp.MustCompile(`^[A-Za-z0-9+._:-]+$`)
+	filterValueRe = regexp.MustCompile(`^[A-Za-z0-9=:()/'*+,._-]+$`)
+)
+
+// ValidateArgs checks encoder options received from a client.
+func ValidateArgs(args []string) error {
+	for i := 0; i < len(args); i++ {
+		opt := args[i]
+		takesValue, ok := allowedOptions[opt]
+		if !ok {
+			return fmt.Errorf("許可されていないオプションです: %s", opt)
+		}
+		if !takesValue {
+			continue
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("オプション %s に値がありません", opt)
+		}
+		i++
+		value := args[i]
+		switch opt {
+		case "-c:v", "-vcodec":
+			if !slices.Contains(encoders, value) {
+				return fmt.Errorf("許可されていないエンコーダです: %s", value)
+			}
+		case "-vf":
+			if err := validateFilter(value); err != nil {
+				return err
+			}
+		default:
+			if !plainValueRe.MatchString(value) {
+				return fmt.Errorf("オプション %s の値が不正です: %q", opt, value)
+			}
+		}
+	}
+	return nil
+}
+
+// validateFilter accepts a plain chain of allowedFilters, e.g.
+// "scale=1280:720,pad=1920:1080:(ow-iw)/2:(oh-ih)/2". Commas inside quotes
+// ('min(1920,iw)') belong to the expression.
+func validateFilter(vf string) error {
+	if !filterValueRe.MatchString(vf) {
+		return fmt.Errorf("フィルタの指定が不正です: %q", vf)
+	}
+	var filters []string
+	start, quoted := 0, false
+	for i, r := range vf {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			filters = append(filters, vf[start:i])
+			start = i + 1
+		}
+	}
+	filters = append(filters, vf[start:])
+
+	for _, f := range filters {
+		name, _, _ := strings.Cut(f, "=")
+		if !slices.Contains(allowedFilters, name) {
+			return fmt.Errorf("許可されていないフィルタです: %s", name)
+		}
+	}
+	return nil
+}
+
+func validContainer(ext string) bool {
+	return slices.Contains(containers, ext)
+}
@@ -0,0 +1,205 @@
+//go:build !windows
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFFmpeg writes a script that stores its arguments in the output file (the last argument).
+func fakeFFmpeg(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	script := "#!/bin/sh\nfor a; do out=$a; done\necho \"$@\" > \"$out\"\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writeChunk(t *testing.T) string {
+	t.Helper()
+	in := filepath.Join(t.TempDir(), "chunk_000.mp4")
+	if err := os.WriteFile(in, []byte("source chunk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return in
+}
+
+func TestValidateArgs(t *testing.T) {
+	ok := [][]string{
+		{"-vcodec", "libx264", "-preset", "faster", "-crf", "23", "-vf", "scale=1280:720", "-movflags", "+faststart", "-an"},
+		{"-c:v", "libsvtav1", "-preset", "8", "-crf", "35", "-vf", "scale='min(1920,iw)':-2:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2"},
+	}
+	for _, args := range ok {
+		if err := ValidateArgs(args); err != nil {
+			t.Errorf("ValidateArgs(%v) = %v", args, err)
+		}
+	}
+
+	bad := [][]string{
+		{"-i", "/etc/passwd"},
+		{"-vcodec", "libx264", "/tmp/out.mp4"},
+		{"-c:v", "copy"},
+		{"-vf", "movie=/etc/passwd"},
+		{"-vf", "scale=1280:720[a];[a]null"},
+		{"-crf", "23 -y"},
+		{"-preset"},
+	}
+	for _, args := range bad {
+		if err := ValidateArgs(args); err == nil {
+			t.Errorf("ValidateArgs(%v) must fail", args)
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(NewServer(fakeFFmpeg(t), "secret", 2).Handler())
+	defer srv.Close()
+	client := NewClient("secret")
+
+	h, err := client.Health(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Slots != 2 || h.Busy != 0 {
+		t.Errorf("health = %+v", h)
+	}
+
+	out := filepath.Join(t.TempDir(), "chunk_000.mkv")
+	args := []string{"-vcodec", "libx264", "-crf", "23", "-an"}
+	if err := client.Encode(context.Background(), srv.URL, writeChunk(t), out, args); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	if !strings.Contains(got, "-vcodec libx264 -crf 23 -an -threads ") || !strings.HasSuffix(strings.TrimSpace(got), "output.mkv") {
+		t.Errorf("worker ran ffmpeg with %q", got)
+	}
+}
+
+func TestEncodeRefused(t *testing.T) {
+	srv := httptest.NewServer(NewServer(fakeFFmpeg(t), "secret", 1).Handler())
+	defer srv.Close()
+	in := writeChunk(t)
+	out := filepath.Join(t.TempDir(), "out.mp4")
+
+	// Wrong token
+	err := NewClient("wrong").Encode(context.Background(), srv.URL, in, out, []string{"-an"})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("wrong token: err = %v, want ErrUnavailable", err)
+	}
+
+	// Disallowed option
+	err = NewClient("secret").Encode(context.Background(), srv.URL, in, out, []string{"-f", "null"})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("bad args: err = %v, want ErrUnavailable", err)
+	}
+
+	// Worker gone
+	srv.Close()
+	err = NewClient("secret").Encode(context.Background(), srv.URL, in, out, []string{"-an"})
+	if !errors.Is(err, ErrUnavailable) || errors.Is(err, ErrBusy) {
+		t.Errorf("closed worker: err = %v, want ErrUnavailable", err)
+	}
+	if _, statErr := os.Stat(out); statErr == nil {
+		t.Error("no output may be left after a failed encode")
+	}
+}
+
+func TestEncodeTooLarge(t *testing.T) {
+	s := NewServer(fakeFFmpeg(t), "", 1)
+	s.MaxUpload = 4
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "out.mp4")
+	err := NewClient("").Encode(context.Background(), srv.URL, writeChunk(t), out, []string{"-an"})
+	if !errors.Is(err, ErrUnavailable) || !strings.Contains(err.Error(), "413") {
+		t.Errorf("err = %v, want ErrUnavailable with 413", err)
+	}
+}
+
+func TestEncodeBusy(t *testing.T) {
+	s := NewServer(fakeFFmpeg(t), "", 1)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	s.slots <- struct{}{} // the only slot is taken
+	out := filepath.Join(t.TempDir(), "out.mp4")
+	err := NewClient("").Encode(context.Background(), srv.URL, writeChunk(t), out, []string{"-an"})
+	if !errors.Is(err, ErrBusy) {
+		t.Errorf("err = %v, want ErrBusy", err)
+	}
+}
+
+func TestEncodeFailure(t *testing.T) {
+	failing := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(failing, []byte("#!/bin/sh\necho 'Unknown encoder' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewServer(failing, "", 1).Handler())
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "out.mp4")
+	err := NewClient("").Encode(context.Background(), srv.URL, writeChunk(t), out, []string{"-an"})
+	if err == nil || errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want an encode error", err)
+	}
+	if !strings.Contains(err.Error(), "Unknown encoder") {
+		t.Errorf("error should carry ffmpeg's stderr: %v", err)
+	}
+}
+
+func TestEncodeStalled(t *testing.T) {
+	// A worker that accepted the chunk and then went silent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	client := NewClient("")
+	client.IdleTimeout = 200 * time.Millisecond
+	out := filepath.Join(t.TempDir(), "out.mp4")
+	err := client.Encode(context.Background(), srv.URL, writeChunk(t), out, []string{"-an"})
+	if !errors.Is(err, ErrUnavailable) || !strings.Contains(fmt.Sprint(err), errStalled.Error()) {
+		t.Errorf("err = %v, want ErrUnavailable after the idle timeout", err)
+	}
+}
+
+func TestEncodeHeartbeat(t *testing.T) {
+	// An encode longer than the idle timeout survives thanks to the heartbeats
+	slow := filepath.Join(t.TempDir(), "ffmpeg")
+	script := "#!/bin/sh\nsleep 1\nfor a; do out=$a; done\necho done > \"$out\"\n"
+	if err := os.WriteFile(slow, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(slow, "", 1)
+	s.Heartbeat = 50 * time.Millisecond
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	client := NewClient("")
+	client.IdleTimeout = 300 * time.Millisecond
+	out := filepath.Join(t.TempDir(), "out.mp4")
+	if err := client.Encode(context.Background(), srv.URL, writeChunk(t), out, []string{"-an"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(out); strings.TrimSpace(string(data)) != "done" {
+		t.Errorf("output = %q", data)
+	}
+}
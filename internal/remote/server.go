@@ -0,0 +1,215 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/proc"
+)
+
+// DefaultMaxUpload is the largest chunk a worker accepts. Chunks are a part of
+// one recording; the limit keeps a broken or hostile client from filling the disk.
+const DefaultMaxUpload = 16 << 30
+
+// DefaultHeartbeat is how often a worker reports that an encode is still running.
+// It must stay well below the client's IdleTimeout.
+const DefaultHeartbeat = 10 * time.Second
+
+// Server is the chunk-encoding endpoint of rec-watch worker.
+type Server struct {
+	FFmpegBin string
+	// Token must be sent as "Authorization: Bearer <token>" ("" = no authentication)
+	Token string
+	// MaxUpload is the largest accepted chunk in bytes
+	MaxUpload int64
+	// Heartbeat is the interval of the "102 Processing" responses sent while ffmpeg runs
+	Heartbeat time.Duration
+
+	slots chan struct{}
+}
+
+// NewServer returns a worker that runs at most slots encodes at once.
+func NewServer(ffmpegBin, token string, slots int) *Server {
+	if ffmpegBin == "" {
+		ffmpegBin = "ffmpeg"
+	}
+	if slots < 1 {
+		slots = 1
+	}
+	return &Server{FFmpegBin: ffmpegBin, Token: token, MaxUpload: DefaultMaxUpload, Heartbeat: DefaultHeartbeat, slots: make(chan struct{}, slots)}
+}
+
+// Handler returns the HTTP handler serving /v1/health and /v1/encode.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+healthPath, s.handleHealth)
+	mux.HandleFunc("POST "+encodePath, s.handleEncode)
+	return mux
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Token == "" {
+		return true
+	}
+	got := []byte(r.Header.Get("Authorization"))
+	want := []byte("Bearer " + s.Token)
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Health{Slots: cap(s.slots), Busy: len(s.slots)})
+}
+
+func (s *Server) handleEncode(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var args []string
+	if err := json.Unmarshal([]byte(r.Header.Get(argsHeader)), &args); err != nil {
+		http.Error(w, "invalid "+argsHeader+" header", http.StatusBadRequest)
+		return
+	}
+	if err := ValidateArgs(args); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	inExt, outExt := r.URL.Query().Get("in"), r.URL.Query().Get("out")
+	if !validContainer(inExt) || !validContainer(outExt) {
+		http.Error(w, "unsupported container", http.StatusBadRequest)
+		return
+	}
+	if r.ContentLength > s.MaxUpload {
+		http.Error(w, "chunk too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	default:
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+		return
+	}
+
+	workDir, err := os.MkdirTemp("", "rec-watch-worker-*")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	inPath := filepath.Join(workDir, "input"+inExt)
+	outPath := filepath.Join(workDir, "output"+outExt)
+	if err := receiveFile(inPath, http.MaxBytesReader(w, r.Body, s.MaxUpload)); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			log.Printf("⚠️ チャンクが大きすぎるため拒否しました (%s, 上限 %dMB)", r.RemoteAddr, s.MaxUpload/1024/1024)
+			http.Error(w, "chunk too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		// The client went away while uploading
+		log.Printf("⚠️ チャンクの受信に失敗: %s: %v", r.RemoteAddr, err)
+		return
+	}
+
+	log.Printf("📥 チャンクを変換中 (%s)", r.RemoteAddr)
+	ffmpegArgs := append([]string{"-hide_banner", "-nostdin", "-loglevel", "error", "-i", inPath}, args...)
+	// Share the machine between the slots like the local Scheduler does
+	threads := max(1, runtime.NumCPU()/cap(s.slots))
+	ffmpegArgs = append(ffmpegArgs, "-threads", strconv.Itoa(threads), "-y", outPath)
+	cmd := proc.Command(r.Context(), s.FFmpegBin, ffmpegArgs...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := s.run(w, cmd); err != nil {
+		if r.Context().Err() != nil {
+			log.Printf("⏹ クライアントが切断したため中止しました (%s)", r.RemoteAddr)
+			return
+		}
+		log.Printf("❌ チャンク変換失敗 (%s): %v", r.RemoteAddr, err)
+		http.Error(w, fmt.Sprintf("ffmpeg実行エラー: %v\n%s", err, lastBytes(stderr.Bytes(), 4096)), http.StatusInternalServerError)
+		return
+	}
+
+	f, err := os.Open(outPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("⚠️ 変換結果の送信に失敗 (%s): %v", r.RemoteAddr, err)
+		return
+	}
+	log.Printf("✅ チャンク変換完了 (%s, %.1fMB)", r.RemoteAddr, float64(info.Size())/1024/1024)
+}
+
+// run runs cmd, sending a "102 Processing" every Heartbeat so the client can tell
+// a long encode from a worker that went away.
+func (s *Server) run(w http.ResponseWriter, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	interval := s.Heartbeat
+	if interval <= 0 {
+		interval = DefaultHeartbeat
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			w.WriteHeader(http.StatusProcessing)
+		}
+	}
+}
+
+func receiveFile(path string, body io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func lastBytes(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[len(b)-n:]
+	}
+	return b
+}
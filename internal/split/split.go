@@ -1,11 +1,17 @@
 package split
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"os/exec"
+	"math"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mt4110/rec-watch/internal/proc"
 )
 
 // Splitter handles file segmentation
@@ -23,7 +29,35 @@ func New(ffmpegBin string) *Splitter {
 // Split divides the input file into chunks in the output directory.
 // Returns a list of generated chunk file paths.
 // segmentTime is in seconds (e.g., 300 for 5 minutes).
-func (s *Splitter) Split(inFile string, outDir string, segmentTime int) ([]string, error) {
+// Cancelling ctx kills ffmpeg; the caller owns outDir and removes leftovers.
+func (s *Splitter) Split(ctx context.Context, inFile string, outDir string, segmentTime int) ([]string, error) {
+	return s.segment(ctx, inFile, outDir, "-segment_time", fmt.Sprintf("%d", segmentTime))
+}
+
+// SplitAt divides the input at the given times (seconds, see BalancedCuts) into chunks
+// in outDir. The times should be keyframe positions so that stream copy can cut there.
+func (s *Splitter) SplitAt(ctx context.Context, inFile string, outDir string, cuts []float64) ([]string, error) {
+	if len(cuts) == 0 {
+		return nil, fmt.Errorf("split failed: no cut points")
+	}
+	times := make([]string, len(cuts))
+	for i, t := range cuts {
+		// The segment muxer cuts at the first keyframe at or after each time;
+		// stay a little before the keyframe so rounding never skips to the next one.
+		times[i] = fmt.Sprintf("%.3f", max(t-cutEpsilon, 0))
+	}
+	return s.segment(ctx, inFile, outDir, "-segment_times", strings.Join(times, ","))
+}
+
+// Margin subtracted from keyframe times passed to -segment_times
+const cutEpsilon = 0.005
+
+// SplitArgs returns the ffmpeg arguments Split runs (shown by dry runs).
+func SplitArgs(inFile string, outDir string, segmentTime int) []string {
+	return segmentArgs(inFile, outDir, "-segment_time", fmt.Sprintf("%d", segmentTime))
+}
+
+func segmentArgs(inFile string, outDir string, extra ...string) []string {
 	// Pattern for output segments: chunk_000.mp4, chunk_001.mp4...
 	// We use .mp4 container for segments to keep it simple, or .ts if keyframe issues
 	// But .mp4 with 'segment' muxer and reset_timestamps should be fine for re-concatenating if we re-encode them.
@@ -37,14 +71,23 @@ func (s *Splitter) Split(inFile string, outDir string, segmentTime int) ([]strin
 		"-c", "copy",
 		"-map", "0",
 		"-f", "segment",
-		"-segment_time", fmt.Sprintf("%d", segmentTime),
+	}
+	args = append(args, extra...)
+	return append(args,
 		"-reset_timestamps", "1", // Important for independent chunks
 		outPattern,
-	}
+	)
+}
+
+func (s *Splitter) segment(ctx context.Context, inFile string, outDir string, extra ...string) ([]string, error) {
+	args := segmentArgs(inFile, outDir, extra...)
 
-	cmd := exec.Command(s.FFmpegBin, args...)
+	cmd := proc.Command(ctx, s.FFmpegBin, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		return nil, fmt.Errorf("split failed: %v\n%s", err, string(output))
 	}
 
@@ -58,3 +101,154 @@ func (s *Splitter) Split(inFile string, outDir string, segmentTime int) ([]strin
 	log.Printf("🔪 分割完了: %s -> %d チャンク", filepath.Base(inFile), len(files))
 	return files, nil
 }
+
+// Sample cuts length seconds of the first video stream starting at start into outPath
+// (stream copy, so the cut snaps to the keyframe before start). Audio is dropped.
+// outPath should be .mkv so that any source codec can be copied.
+func (s *Splitter) Sample(ctx context.Context, inFile string, outPath string, start, length float64) error {
+	args := []string{
+		"-ss", fmt.Sprintf("%.3f", start),
+		"-i", inFile,
+		"-t", fmt.Sprintf("%.3f", length),
+		"-map", "0:v:0",
+		"-an",
+		"-c", "copy",
+		"-y", outPath,
+	}
+
+	cmd := proc.Command(ctx, s.FFmpegBin, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("sample failed: %v\n%s", err, string(output))
+	}
+	return nil
+}
+
+// SamplePoints returns count start positions of length-second samples spread evenly
+// over duration (e.g. at 25%, 50%, 75% for 3 samples). Inputs too short for separate
+// samples yield a single sample from the start.
+func SamplePoints(duration float64, count int, length float64) []float64 {
+	if count < 1 || duration <= float64(count)*length*2 {
+		return []float64{0}
+	}
+	points := make([]float64, count)
+	for i := range points {
+		points[i] = duration*float64(i+1)/float64(count+1) - length/2
+	}
+	return points
+}
+
+// Scene score (0-1, the mean frame difference) above which two consecutive
+// keyframes count as a scene change
+const sceneThreshold = 0.3
+
+// SceneChanges returns the times (seconds, sorted) of keyframes that start a new
+// scene. Only keyframes are decoded (at a reduced size) and each is compared with
+// the previous one, so a cut there puts the scene change at the chunk boundary.
+func (s *Splitter) SceneChanges(ctx context.Context, inFile string) ([]float64, error) {
+	args := []string{
+		"-hide_banner", "-nostdin",
+		"-skip_frame", "nokey",
+		"-i", inFile,
+		"-map", "0:v:0",
+		"-an",
+		"-vf", fmt.Sprintf("scale=320:-2,select='gt(scene,%g)',showinfo", sceneThreshold),
+		"-f", "null", "-",
+	}
+
+	cmd := proc.Command(ctx, s.FFmpegBin, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("scene detection failed: %v\n%s", err, lastLines(output, 5))
+	}
+	return ParseSceneChanges(output), nil
+}
+
+var showinfoPTS = regexp.MustCompile(`\bpts_time:\s*(-?[0-9.]+)`)
+
+// ParseSceneChanges returns the sorted frame times of showinfo output.
+func ParseSceneChanges(data []byte) []float64 {
+	var times []float64
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.Contains(line, "showinfo") {
+			continue
+		}
+		m := showinfoPTS.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if t, err := strconv.ParseFloat(m[1], 64); err == nil && t > 0 {
+			times = append(times, t)
+		}
+	}
+	sort.Float64s(times)
+	return times
+}
+
+func lastLines(b []byte, n int) string {
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// BalancedCuts picks cut points from keyframes so that the input of duration seconds
+// is divided into chunks of about segment seconds each, all of similar length.
+// The number of chunks is fixed first (duration / segment). Each ideal boundary
+// moves to the nearest scene change (see SceneChanges) within segment/5 of it, so
+// that chunks start with a new scene; without one it snaps to the nearest keyframe.
+// scenes may be nil. Returns nil if the input should not be split.
+func BalancedCuts(keyframes, scenes []float64, duration float64, segment float64) []float64 {
+	if segment <= 0 || duration <= 0 {
+		return nil
+	}
+	n := int(math.Round(duration / segment))
+	if n < 2 || len(keyframes) < 2 {
+		return nil
+	}
+	// A chunk shorter than this is not worth an extra ffmpeg process
+	minGap := segment / 4
+	// How far a boundary may move to reach a scene change
+	window := segment / 5
+
+	var cuts []float64
+	last := 0.0
+	for i := 1; i < n; i++ {
+		ideal := duration * float64(i) / float64(n)
+		k := nearest(keyframes, ideal)
+		if len(scenes) > 0 {
+			if sc := nearest(scenes, ideal); math.Abs(sc-ideal) <= window {
+				// Scene times are keyframes already; snapping keeps the cut copyable
+				k = nearest(keyframes, sc)
+			}
+		}
+		if k-last < minGap || duration-k < minGap {
+			continue
+		}
+		cuts = append(cuts, k)
+		last = k
+	}
+	return cuts
+}
+
+// nearest returns the value in sorted that is closest to v.
+func nearest(sorted []float64, v float64) float64 {
+	i := sort.SearchFloat64s(sorted, v)
+	switch {
+	case i == 0:
+		return sorted[0]
+	case i == len(sorted):
+		return sorted[len(sorted)-1]
+	case v-sorted[i-1] <= sorted[i]-v:
+		return sorted[i-1]
+	default:
+		return sorted[i]
+	}
+}
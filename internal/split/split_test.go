@@ -0,0 +1,90 @@
+package split
+
+import (
+	"context"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSamplePoints(t *testing.T) {
+	if got := SamplePoints(600, 3, 10); !reflect.DeepEqual(got, []float64{145, 295, 445}) {
+		t.Errorf("SamplePoints(600, 3, 10) = %v", got)
+	}
+	// Too short for three separate samples
+	if got := SamplePoints(40, 3, 10); !reflect.DeepEqual(got, []float64{0}) {
+		t.Errorf("SamplePoints(40, 3, 10) = %v", got)
+	}
+}
+
+func TestBalancedCuts(t *testing.T) {
+	// Keyframes every 2s over 1000s: 1000/300 -> 3 chunks of ~333s
+	var kf []float64
+	for t := 0.0; t < 1000; t += 2 {
+		kf = append(kf, t)
+	}
+	got := BalancedCuts(kf, nil, 1000, 300)
+	if !reflect.DeepEqual(got, []float64{334, 666}) {
+		t.Errorf("BalancedCuts() = %v", got)
+	}
+
+	// Sparse keyframes snap to the nearest one
+	got = BalancedCuts([]float64{0, 100, 290, 420, 700, 900}, nil, 1000, 300)
+	if !reflect.DeepEqual(got, []float64{290, 700}) {
+		t.Errorf("BalancedCuts() sparse = %v", got)
+	}
+
+	// Fixed GOP: boundaries move to scene changes within segment/5 (60s);
+	// the one at 500s is too far from 666 and is ignored
+	got = BalancedCuts(kf, []float64{40, 310, 500}, 1000, 300)
+	if !reflect.DeepEqual(got, []float64{310, 666}) {
+		t.Errorf("BalancedCuts() with scenes = %v", got)
+	}
+
+	// Shorter than 1.5 segments: no split
+	if got := BalancedCuts(kf, nil, 400, 300); got != nil {
+		t.Errorf("expected no cuts, got %v", got)
+	}
+}
+
+func TestParseSceneChanges(t *testing.T) {
+	out := `Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':
+[Parsed_showinfo_2 @ 0x600000f2c000] config in time_base: 1/15360, frame_rate: 30/1
+[Parsed_showinfo_2 @ 0x600000f2c000] n:   0 pts: 184320 pts_time:12      duration:    512 fmt:yuv420p
+[Parsed_showinfo_2 @ 0x600000f2c000] n:   1 pts:  61440 pts_time:4.5     duration:    512 fmt:yuv420p
+frame=    2 fps=0.0 q=-0.0 Lsize=N/A time=00:00:12.03 bitrate=N/A speed= 500x
+`
+	if got := ParseSceneChanges([]byte(out)); !reflect.DeepEqual(got, []float64{4.5, 12}) {
+		t.Errorf("ParseSceneChanges() = %v", got)
+	}
+}
+
+func TestSceneChanges(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not installed")
+	}
+	// Red for 4s, then blue; a keyframe every second (fixed GOP)
+	in := filepath.Join(t.TempDir(), "in.mp4")
+	cmd := exec.Command("ffmpeg", "-v", "error",
+		"-f", "lavfi", "-i", "color=c=red:s=160x120:r=15:d=4",
+		"-f", "lavfi", "-i", "color=c=blue:s=160x120:r=15:d=4",
+		"-filter_complex", "[0:v][1:v]concat=n=2:v=1:a=0",
+		"-c:v", "libx264", "-g", "15", "-pix_fmt", "yuv420p", "-y", in)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot create the fixture: %v\n%s", err, out)
+	}
+
+	scenes, err := New("").SceneChanges(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, sc := range scenes {
+		found = found || math.Abs(sc-4) < 0.1
+	}
+	if !found {
+		t.Errorf("scene changes = %v, want one at 4s", scenes)
+	}
+}
@@ -3,6 +3,9 @@ package tui
 import (
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -26,13 +29,19 @@ var (
 type tickMsg time.Time
 
 type Model struct {
-	cfg *config.Config
+	cfg       *config.Config
+	watchDirs []string
+	lost      map[string]watcher.DirStatus // watch dirs waiting to be re-added
 	// State variables (Queue, Recent, Stats)
 	// State variables (Queue, Recent, Stats)
 	queue   []string
 	paths   []string // Parallel to queue to store full paths
 	history []string
 
+	// Live progress of running conversions (keyed by path, in start order)
+	active   []string
+	progress map[string]watcher.ProgressEvent
+
 	cursor int // Cursor position in queue
 
 	sub chan interface{} // Subscription to watcher events
@@ -40,11 +49,14 @@ type Model struct {
 
 func NewModel(cfg *config.Config, sub chan interface{}) Model {
 	return Model{
-		cfg:     cfg,
-		queue:   []string{},
-		paths:   []string{},
-		history: []string{},
-		sub:     sub,
+		cfg:       cfg,
+		watchDirs: cfg.WatchDirs,
+		lost:      map[string]watcher.DirStatus{},
+		queue:     []string{},
+		paths:     []string{},
+		history:   []string{},
+		progress:  map[string]watcher.ProgressEvent{},
+		sub:       sub,
 	}
 }
 
@@ -110,14 +122,46 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		m.history = append([]string{"🚀 Processing: " + msg.Path}, m.history...)
+		m.active = append(m.active, msg.Path)
+		m.progress[msg.Path] = watcher.ProgressEvent{Path: msg.Path, Percent: -1}
+		return m, waitForActivity(m.sub)
+
+	case watcher.ProgressEvent:
+		if _, ok := m.progress[msg.Path]; ok {
+			m.progress[msg.Path] = msg
+		}
 		return m, waitForActivity(m.sub)
 
 	case watcher.SuccessEvent:
 		m.history = append([]string{"✅ Done: " + msg.Path}, m.history...)
+		m.removeActive(msg.Path)
 		return m, waitForActivity(m.sub)
 
 	case watcher.FailureEvent:
 		m.history = append([]string{"❌ Failed: " + msg.Path}, m.history...)
+		m.removeActive(msg.Path)
+		return m, waitForActivity(m.sub)
+
+	case watcher.WatchStateEvent:
+		if msg.Dir.State == watcher.StateLost {
+			if _, ok := m.lost[msg.Dir.Path]; !ok {
+				m.history = append([]string{"⚠️ Lost: " + msg.Dir.Path}, m.history...)
+			}
+			m.lost[msg.Dir.Path] = msg.Dir
+		} else if _, ok := m.lost[msg.Dir.Path]; ok {
+			delete(m.lost, msg.Dir.Path)
+			m.history = append([]string{"✅ Reconnected: " + msg.Dir.Path}, m.history...)
+		}
+		return m, waitForActivity(m.sub)
+
+	case watcher.ConfigReloadedEvent:
+		m.watchDirs = msg.WatchDirs
+		for path := range m.lost {
+			if !slices.Contains(msg.WatchDirs, path) {
+				delete(m.lost, path)
+			}
+		}
+		m.history = append([]string{fmt.Sprintf("🔄 設定を再読み込みしました (%d件の変更)", len(msg.Changes))}, m.history...)
 		return m, waitForActivity(m.sub)
 	}
 	return m, nil
@@ -126,7 +170,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m Model) View() string {
 	s := titleStyle.Render("🔴 RecWatch TUI") + "\n\n"
 
-	s += "監視中: " + fmt.Sprintf("%v", m.cfg.WatchDirs) + "\n\n"
+	s += "監視中: " + fmt.Sprintf("%v", m.watchDirs) + "\n"
+	for _, d := range m.lostDirs() {
+		s += fmt.Sprintf("⚠️ 再接続待ち: %s (%s〜, %d回失敗)\n", d.Path, d.Since.Format("15:04:05"), d.Attempts)
+	}
+	s += "\n"
 
 	s += "処理待ちキュー:\n"
 	if len(m.queue) == 0 {
@@ -140,6 +188,13 @@ func (m Model) View() string {
 		s += fmt.Sprintf("%s%s\n", cursor, q)
 	}
 
+	if len(m.active) > 0 {
+		s += "\n変換中:\n"
+		for _, path := range m.active {
+			s += "  " + renderProgress(m.progress[path]) + "  " + filepath.Base(path) + "\n"
+		}
+	}
+
 	s += "\n最近の履歴:\n"
 	if len(m.history) == 0 {
 		s += statusStyle.Render("  (履歴なし)") + "\n"
@@ -152,6 +207,44 @@ func (m Model) View() string {
 	return s
 }
 
+// lostDirs returns the lost watch dirs in path order.
+func (m Model) lostDirs() []watcher.DirStatus {
+	dirs := make([]watcher.DirStatus, 0, len(m.lost))
+	for _, d := range m.lost {
+		dirs = append(dirs, d)
+	}
+	slices.SortFunc(dirs, func(a, b watcher.DirStatus) int { return strings.Compare(a.Path, b.Path) })
+	return dirs
+}
+
+func (m *Model) removeActive(path string) {
+	delete(m.progress, path)
+	for i, p := range m.active {
+		if p == path {
+			m.active = append(m.active[:i], m.active[i+1:]...)
+			break
+		}
+	}
+}
+
+// renderProgress draws e.g. "[██████░░░░░░░░░░]  37.5% 1.8x 残り 2m10s"
+func renderProgress(p watcher.ProgressEvent) string {
+	const width = 20
+	if p.Percent < 0 {
+		return statusStyle.Render("[" + strings.Repeat("·", width) + "]    --%")
+	}
+	filled := int(p.Percent / 100 * width)
+	bar := "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+	s := fmt.Sprintf("%s %5.1f%%", bar, p.Percent)
+	if p.Speed > 0 {
+		s += fmt.Sprintf(" %.1fx", p.Speed)
+	}
+	if p.ETA > 0 {
+		s += " 残り " + p.ETA.Truncate(time.Second).String()
+	}
+	return s
+}
+
 func tickCmd() tea.Cmd {
 	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
 		return tickMsg(t)
@@ -0,0 +1,32 @@
+//go:build darwin
+
+package watcher
+
+import (
+	"strings"
+	"syscall"
+)
+
+// networkFSType returns the filesystem type of path if changes on it are known not
+// to produce FSEvents/kqueue events (network and FUSE filesystems), otherwise "".
+func networkFSType(path string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		b.WriteByte(byte(c))
+	}
+	name := b.String()
+	switch {
+	case name == "nfs", name == "smbfs", name == "afpfs", name == "webdav", name == "cifs":
+		return name
+	case strings.Contains(name, "fuse"):
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package watcher
+
+import "syscall"
+
+// Filesystem magic numbers (statfs f_type) whose remote changes inotify does not see
+var pollFSTypes = map[uint32]string{
+	0x6969:     "nfs",
+	0x517B:     "smb",
+	0xFF534D42: "cifs",
+	0xFE534D42: "smb2",
+	0x65735546: "fuse",
+	0x01021997: "9p",
+	0x00C36400: "ceph",
+	0x5346414F: "afs",
+}
+
+// networkFSType returns the filesystem type of path if changes on it are known not
+// to produce inotify events (network and FUSE filesystems), otherwise "".
+func networkFSType(path string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return ""
+	}
+	return pollFSTypes[uint32(st.Type)]
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package watcher
+
+// networkFSType is not implemented on this platform; use watchBackend or pollDirs.
+func networkFSType(path string) string {
+	return ""
+}
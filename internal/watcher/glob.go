@@ -0,0 +1,162 @@
+package watcher
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Default of globInterval
+const defaultGlobInterval = 10 * time.Second
+
+// isPattern reports whether a watchDirs entry is a glob pattern rather than a path.
+func isPattern(dir string) bool {
+	return strings.ContainsAny(dir, "*?[{")
+}
+
+// checkPatterns validates the glob patterns of watchDirs.
+func checkPatterns(dirs []string) error {
+	for _, dir := range dirs {
+		if isPattern(dir) && !doublestar.ValidatePathPattern(expandHome(dir)) {
+			return fmt.Errorf("watchDirs のパターンが不正です: %s", dir)
+		}
+	}
+	return nil
+}
+
+// MatchWatchPattern returns the directories a watchDirs glob pattern matches now
+// (absolute paths; a leading ~ is the home directory).
+func MatchWatchPattern(pattern string) ([]string, error) {
+	pattern, err := filepath.Abs(expandHome(pattern))
+	if err != nil {
+		return nil, err
+	}
+	matches, err := doublestar.FilepathGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	base, _ := doublestar.SplitPattern(filepath.ToSlash(pattern))
+	return slices.DeleteFunc(matches, func(match string) bool {
+		if hiddenBelow(filepath.FromSlash(base), match) {
+			return true
+		}
+		info, err := os.Stat(match)
+		return err != nil || !info.IsDir()
+	}), nil
+}
+
+// hiddenBelow reports whether a path element of path below base starts with a
+// dot (wildcards match hidden directories too).
+func hiddenBelow(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return false
+	}
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.HasPrefix(elem, ".") && elem != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveWatchDirs returns the absolute watch dirs of the config: paths as they
+// are, patterns expanded (doublestar) to the directories they match now.
+// globbed holds the dirs that came from a pattern. With recursive, matches
+// below another watch dir are left out, as they are watched with it.
+func (w *Watcher) resolveWatchDirs() (dirs []string, globbed map[string]bool) {
+	cfg := w.conf()
+	globbed = map[string]bool{}
+	for _, entry := range cfg.WatchDirs {
+		entry = expandHome(entry)
+		if !isPattern(entry) {
+			if abs, err := filepath.Abs(entry); err != nil {
+				log.Printf("⚠️ ディレクトリパスの解決に失敗 (スキップ): %s -> %v", entry, err)
+			} else if !slices.Contains(dirs, abs) {
+				dirs = append(dirs, abs)
+			}
+			continue
+		}
+
+		matches, err := MatchWatchPattern(entry)
+		if err != nil {
+			log.Printf("⚠️ パターン '%s' の検索に失敗しました: %v", entry, err)
+			continue
+		}
+		for _, match := range matches {
+			if w.ignoredDir(match) || slices.Contains(dirs, match) {
+				continue
+			}
+			dirs = append(dirs, match)
+			globbed[match] = true
+		}
+	}
+
+	if cfg.Recursive {
+		dirs = slices.DeleteFunc(dirs, func(dir string) bool {
+			if !globbed[dir] {
+				return false
+			}
+			for _, other := range dirs {
+				if other != dir && isWithin(dir, other) {
+					delete(globbed, dir)
+					return true
+				}
+			}
+			return false
+		})
+	}
+	return dirs, globbed
+}
+
+// syncRoots watches the dirs watchDirs resolve to now and drops the others.
+// With rewatch, the dirs that stay are watched again as well (options that
+// decide how a tree is watched changed). New dirs are scanned, as files
+// already in them produce no events.
+func (w *Watcher) syncRoots(rewatch bool) {
+	wanted, globbed := w.resolveWatchDirs()
+	w.mu.Lock()
+	w.globbed = globbed
+	w.mu.Unlock()
+
+	current := w.watchRoots()
+	for _, root := range current {
+		if !slices.Contains(wanted, root) {
+			w.removeRoot(root)
+			log.Printf("監視を終了しました: %s", root)
+		} else if rewatch {
+			w.removeRoot(root)
+		}
+	}
+	for _, dir := range wanted {
+		if !rewatch && slices.Contains(current, dir) {
+			continue
+		}
+		root := w.addRoot(dir)
+		if root == "" || slices.Contains(current, root) || w.conf().NoScan {
+			continue
+		}
+		if n := w.scan([]string{root}); n > 0 {
+			log.Printf("🔎 未変換のファイルを %d件 見つけました: %s", n, root)
+		}
+	}
+}
+
+// isGlobbed reports whether root was matched by a watchDirs pattern (and is
+// dropped instead of retried when it disappears).
+func (w *Watcher) isGlobbed(root string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.globbed[root]
+}
+
+// hasPatterns reports whether watchDirs contains glob patterns.
+func (w *Watcher) hasPatterns() bool {
+	return slices.ContainsFunc(w.conf().WatchDirs, isPattern)
+}
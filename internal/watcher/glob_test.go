@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mt4110/rec-watch/internal/config"
+)
+
+func TestResolveWatchDirs(t *testing.T) {
+	media := t.TempDir()
+	for _, dir := range []string{"cardA/DCIM/100", "cardB/DCIM", "cardC/MISC", ".trash/DCIM"} {
+		os.MkdirAll(filepath.Join(media, dir), 0755)
+	}
+	os.WriteFile(filepath.Join(media, "cardC", "DCIM"), []byte("not a dir"), 0644)
+	literal := t.TempDir()
+
+	cfg := config.NewDefault()
+	cfg.WatchDirs = []string{literal, filepath.Join(media, "*", "DCIM")}
+	w := &Watcher{Cfg: cfg}
+
+	dirs, globbed := w.resolveWatchDirs()
+	want := []string{literal, filepath.Join(media, "cardA", "DCIM"), filepath.Join(media, "cardB", "DCIM")}
+	if !slices.Equal(dirs, want) {
+		t.Errorf("dirs = %v, want %v", dirs, want)
+	}
+	if globbed[literal] || !globbed[want[1]] || len(globbed) != 2 {
+		t.Errorf("globbed = %v", globbed)
+	}
+
+	// ** matches the whole tree; recursive watching only needs its top
+	cfg.WatchDirs = []string{filepath.Join(media, "cardA", "**")}
+	cfg.Recursive = true
+	if dirs, _ := w.resolveWatchDirs(); !slices.Equal(dirs, []string{filepath.Join(media, "cardA")}) {
+		t.Errorf("recursive dirs = %v", dirs)
+	}
+	cfg.Recursive = false
+	if dirs, _ := w.resolveWatchDirs(); len(dirs) != 3 {
+		t.Errorf("non-recursive dirs = %v, want cardA, DCIM and 100", dirs)
+	}
+
+	if err := checkPatterns([]string{filepath.Join(media, "[card")}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
+
+func TestSyncRootsFollowsPatterns(t *testing.T) {
+	w, _ := newHealTest(t)
+	media := t.TempDir()
+	os.MkdirAll(filepath.Join(media, "cardA", "DCIM"), 0755)
+	w.Cfg.WatchDirs = []string{filepath.Join(media, "*", "DCIM")}
+
+	w.syncRoots(false)
+	cardA := filepath.Join(media, "cardA", "DCIM")
+	if got := w.watchRoots(); !slices.Equal(got, []string{cardA}) {
+		t.Fatalf("roots = %v, want %v", got, []string{cardA})
+	}
+
+	// A card is inserted with recordings on it
+	cardB := filepath.Join(media, "cardB", "DCIM")
+	os.MkdirAll(cardB, 0755)
+	os.WriteFile(filepath.Join(cardB, "clip.mp4"), []byte("x"), 0644)
+	w.syncRoots(false)
+	if got := w.watchRoots(); !slices.Equal(got, []string{cardA, cardB}) {
+		t.Errorf("roots after insert = %v", got)
+	}
+	if !w.stable.isPending(filepath.Join(cardB, "clip.mp4")) {
+		t.Error("recording on the new card not detected")
+	}
+
+	// An ejected card is dropped instead of retried
+	os.RemoveAll(filepath.Join(media, "cardA"))
+	w.checkRoots()
+	if got := w.watchRoots(); !slices.Equal(got, []string{cardB}) {
+		t.Errorf("roots after eject = %v", got)
+	}
+	if slices.Contains(w.fsw.WatchList(), cardA) {
+		t.Error("ejected card still watched")
+	}
+}
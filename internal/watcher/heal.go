@@ -0,0 +1,276 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/config"
+)
+
+// Watch dirs are checked this often, and lost ones re-added with a backoff
+// between retryMin and retryMax.
+const (
+	healInterval = 2 * time.Second
+	retryMin     = time.Second
+	retryMax     = 5 * time.Minute
+)
+
+// Watch dir states (DirStatus.State)
+const (
+	StateWatching = "watching"
+	StateLost     = "lost"
+)
+
+// rootState tracks whether a watch dir is still watched. A dir is lost when it
+// is removed, renamed or unmounted: the watches are gone even if a directory
+// with the same name comes back (sync tools recreate them, drives are
+// remounted), so it is re-added until that succeeds.
+type rootState struct {
+	backend  string
+	info     os.FileInfo // the directory the watches were added for
+	lost     time.Time   // zero while watched
+	since    time.Time
+	attempts int
+	retryAt  time.Time
+	err      string
+}
+
+// WatchStatus is written to StatusPath while watch mode runs (read by doctor).
+type WatchStatus struct {
+	PID     int         `json:"pid"`
+	Started time.Time   `json:"started"`
+	Updated time.Time   `json:"updated"`
+	Dirs    []DirStatus `json:"dirs"`
+}
+
+type DirStatus struct {
+	Path     string    `json:"path"`
+	Backend  string    `json:"backend"` // fsnotify or poll
+	State    string    `json:"state"`   // StateWatching or StateLost
+	Since    time.Time `json:"since"`
+	Attempts int       `json:"attempts,omitempty"` // failed re-adds while lost
+	Error    string    `json:"error,omitempty"`
+}
+
+// StatusPath is the status file of a running watcher.
+func StatusPath(cfg *config.Config) string {
+	return filepath.Join(cfg.StatePath(), "watch-status.json")
+}
+
+// ReadStatus reads the status file. It returns an error satisfying
+// errors.Is(err, fs.ErrNotExist) if watch mode is not running.
+func ReadStatus(cfg *config.Config) (*WatchStatus, error) {
+	data, err := os.ReadFile(StatusPath(cfg))
+	if err != nil {
+		return nil, err
+	}
+	var st WatchStatus
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("状態ファイルが壊れています: %w", err)
+	}
+	return &st, nil
+}
+
+// watchRoot adds the watches of a watch dir and marks it as watched.
+func (w *Watcher) watchRoot(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("ディレクトリではありません: %s", root)
+	}
+
+	backend, reason := w.backendFor(root)
+	if backend == backendPoll {
+		if err := w.poll.add(root); err != nil {
+			return err
+		}
+		log.Printf("監視を開始しました: %s (ポーリング %v, %s)", root, w.poll.currentInterval(), reason)
+	} else {
+		n := w.addTree(root)
+		if n == 0 {
+			return errors.New("監視を追加できません")
+		}
+		if n > 1 {
+			log.Printf("監視を開始しました: %s (サブディレクトリ %d個を含む)", root, n-1)
+		} else {
+			log.Printf("監視を開始しました: %s", root)
+		}
+	}
+
+	w.mu.Lock()
+	w.health[root] = &rootState{backend: backend, info: info, since: time.Now()}
+	w.mu.Unlock()
+	w.statusChanged(root)
+	return nil
+}
+
+// unwatchRoot removes the watches of a watch dir (and its subdirectories).
+func (w *Watcher) unwatchRoot(root string) {
+	if w.poll.has(root) {
+		w.poll.remove(root)
+		return
+	}
+	for _, path := range w.fsw.WatchList() {
+		if isWithin(path, root) {
+			w.fsw.Remove(path)
+		}
+	}
+}
+
+// rootLost drops the watches of a lost watch dir and schedules re-adding it.
+func (w *Watcher) rootLost(root string, cause error) {
+	if w.isGlobbed(root) {
+		// It no longer matches its pattern; it comes back if it matches again
+		w.removeRoot(root)
+		log.Printf("監視を終了しました (見つからなくなりました): %s", root)
+		return
+	}
+	w.unwatchRoot(root)
+
+	now := time.Now()
+	w.mu.Lock()
+	st, ok := w.health[root]
+	if !ok {
+		st = &rootState{}
+		w.health[root] = st
+	}
+	if !st.lost.IsZero() {
+		w.mu.Unlock()
+		return
+	}
+	st.lost, st.since, st.attempts, st.retryAt = now, now, 0, now.Add(retryMin)
+	st.err = cause.Error()
+	w.mu.Unlock()
+
+	log.Printf("⚠️ 監視対象を見失いました (再接続を試みます): %s -> %v", root, cause)
+	w.statusChanged(root)
+}
+
+// checkRoots detects lost watch dirs and retries the lost ones that are due.
+// fsnotify reports the removal or rename of a watched dir, but not every
+// unmount, so the dirs are also compared with the ones the watches were added for.
+func (w *Watcher) checkRoots() {
+	now := time.Now()
+	for _, root := range w.watchRoots() {
+		w.mu.RLock()
+		st, ok := w.health[root]
+		var lost bool
+		var info os.FileInfo
+		var retryAt time.Time
+		if ok {
+			lost, info, retryAt = !st.lost.IsZero(), st.info, st.retryAt
+		}
+		w.mu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		if !lost {
+			cur, err := os.Stat(root)
+			switch {
+			case err != nil:
+				w.rootLost(root, err)
+			case !os.SameFile(cur, info):
+				w.rootLost(root, errors.New("別のディレクトリに置き換わりました (再作成・再マウント)"))
+			}
+			continue
+		}
+		if now.Before(retryAt) {
+			continue
+		}
+		w.retryRoot(root)
+	}
+}
+
+// retryRoot tries to re-add a lost watch dir. Files that arrived while it was
+// lost are picked up by a scan.
+func (w *Watcher) retryRoot(root string) {
+	// Leftover watches of the old directory
+	w.unwatchRoot(root)
+
+	if err := w.watchRoot(root); err != nil {
+		w.mu.Lock()
+		st := w.health[root]
+		st.attempts++
+		wait := min(retryMin<<min(st.attempts, 16), retryMax)
+		st.retryAt = time.Now().Add(wait)
+		st.err = err.Error()
+		attempts := st.attempts
+		w.mu.Unlock()
+		log.Printf("⏳ 再接続に失敗しました (%d回目, %v後に再試行): %s -> %v", attempts, wait, root, err)
+		w.statusChanged(root)
+		return
+	}
+
+	log.Printf("✅ 監視を再開しました: %s", root)
+	if !w.conf().NoScan {
+		if n := w.scan([]string{root}); n > 0 {
+			log.Printf("🔎 未変換のファイルを %d件 見つけました: %s", n, root)
+		}
+	}
+}
+
+// isRootEvent reports whether an fsnotify event removed or renamed a watch dir itself.
+func (w *Watcher) isRootEvent(name string) bool {
+	return slices.Contains(w.watchRoots(), filepath.Clean(name))
+}
+
+// statusChanged reports the state of root to EventChan and rewrites the status file.
+func (w *Watcher) statusChanged(root string) {
+	status := w.status()
+	for _, d := range status.Dirs {
+		if d.Path == root {
+			w.emit(WatchStateEvent{Dir: d})
+		}
+	}
+	w.writeStatus(status)
+}
+
+// status collects the state of all watch dirs.
+func (w *Watcher) status() *WatchStatus {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	st := &WatchStatus{PID: os.Getpid(), Started: w.started, Updated: time.Now()}
+	for _, root := range w.roots {
+		h, ok := w.health[root]
+		if !ok {
+			continue
+		}
+		d := DirStatus{Path: root, Backend: h.backend, State: StateWatching, Since: h.since}
+		if !h.lost.IsZero() {
+			d.State, d.Attempts, d.Error = StateLost, h.attempts, h.err
+		}
+		st.Dirs = append(st.Dirs, d)
+	}
+	return st
+}
+
+// writeStatus replaces the status file atomically.
+func (w *Watcher) writeStatus(st *WatchStatus) {
+	if w.statusPath == "" {
+		return
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return
+	}
+	tmp := w.statusPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("状態ファイルの書き込みに失敗: %v", err)
+		return
+	}
+	os.Rename(tmp, w.statusPath)
+}
+
+// WatchStateEvent is sent when a watch dir is (re)added or lost.
+type WatchStateEvent struct {
+	Dir DirStatus
+}
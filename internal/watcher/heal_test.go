@@ -0,0 +1,122 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/mt4110/rec-watch/internal/config"
+)
+
+func newHealTest(t *testing.T) (*Watcher, string) {
+	t.Helper()
+	cfg := config.NewDefault()
+	cfg.StateDir = t.TempDir()
+	cfg.DestDir = t.TempDir()
+	w := newReloadTest(t, cfg)
+	w.statusPath = StatusPath(cfg)
+
+	root := filepath.Join(t.TempDir(), "recordings")
+	os.Mkdir(root, 0755)
+	if w.addRoot(root) != root {
+		t.Fatal("root not added")
+	}
+	return w, root
+}
+
+// retryNow makes the next checkRoots retry root.
+func retryNow(w *Watcher, root string) {
+	w.mu.Lock()
+	w.health[root].retryAt = time.Time{}
+	w.mu.Unlock()
+}
+
+func dirState(t *testing.T, w *Watcher, root string) DirStatus {
+	t.Helper()
+	st, err := ReadStatus(w.conf())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range st.Dirs {
+		if d.Path == root {
+			return d
+		}
+	}
+	t.Fatalf("%s missing from the status file", root)
+	return DirStatus{}
+}
+
+func TestHealRemovedRoot(t *testing.T) {
+	w, root := newHealTest(t)
+	if got := dirState(t, w, root); got.State != StateWatching || got.Backend != backendFsnotify {
+		t.Fatalf("initial state = %+v", got)
+	}
+
+	// A sync tool removes the folder and recreates it with new files
+	os.RemoveAll(root)
+	w.handleEvent(fsnotify.Event{Name: root, Op: fsnotify.Remove})
+	if got := dirState(t, w, root); got.State != StateLost {
+		t.Fatalf("state after removal = %+v", got)
+	}
+
+	// Still missing: retried with a growing backoff
+	retryNow(w, root)
+	w.checkRoots()
+	first := w.health[root].retryAt
+	retryNow(w, root)
+	w.checkRoots()
+	if got := dirState(t, w, root); got.Attempts != 2 {
+		t.Errorf("attempts = %d, want 2", got.Attempts)
+	}
+	if wait := time.Until(w.health[root].retryAt); wait <= time.Until(first) {
+		t.Errorf("backoff did not grow: %v", wait)
+	}
+
+	os.Mkdir(root, 0755)
+	os.WriteFile(filepath.Join(root, "take.mov"), []byte("x"), 0644)
+	retryNow(w, root)
+	w.checkRoots()
+	if got := dirState(t, w, root); got.State != StateWatching {
+		t.Fatalf("state after recreation = %+v", got)
+	}
+	if !slices.Contains(w.fsw.WatchList(), root) {
+		t.Error("recreated folder not watched")
+	}
+	if !w.stable.isPending(filepath.Join(root, "take.mov")) {
+		t.Error("file added while the folder was lost not detected")
+	}
+}
+
+func TestHealReplacedRoot(t *testing.T) {
+	w, root := newHealTest(t)
+
+	// Replaced without an event reaching the watcher (e.g. a remount)
+	os.Rename(root, root+".old")
+	os.Mkdir(root, 0755)
+	w.checkRoots()
+	if got := dirState(t, w, root); got.State != StateLost {
+		t.Fatalf("replaced folder not detected: %+v", got)
+	}
+	retryNow(w, root)
+	w.checkRoots()
+	if got := dirState(t, w, root); got.State != StateWatching {
+		t.Errorf("state after retry = %+v", got)
+	}
+}
+
+func TestAddRootMissing(t *testing.T) {
+	w, _ := newHealTest(t)
+
+	// An unmounted drive is kept and retried
+	drive := filepath.Join(t.TempDir(), "SDCARD")
+	if w.addRoot(drive) != drive {
+		t.Fatal("missing root dropped")
+	}
+	if got := dirState(t, w, drive); got.State != StateLost {
+		t.Errorf("state of a missing root = %+v", got)
+	}
+}
@@ -0,0 +1,255 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Default of pollInterval
+const defaultPollInterval = 5 * time.Second
+
+// Watch backends (config watchBackend)
+const (
+	backendAuto     = "auto"
+	backendFsnotify = "fsnotify"
+	backendPoll     = "poll"
+)
+
+// fileStamp is what the poller compares between two listings.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// poller watches directories on filesystems without change notifications (NFS,
+// SMB, FUSE) by listing them every interval and diffing size and mtime of the
+// files. New files are reported through onCreate, changed ones through onWrite.
+type poller struct {
+	skipDir  func(dir string) bool
+	onCreate func(path string)
+	onWrite  func(path string)
+
+	mu        sync.Mutex
+	interval  time.Duration
+	recursive bool
+	roots     map[string]*polledRoot
+}
+
+type polledRoot struct {
+	files   map[string]fileStamp
+	failing bool // logged that the last listing failed
+}
+
+func newPoller(interval time.Duration, recursive bool) *poller {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	return &poller{
+		interval:  interval,
+		recursive: recursive,
+		skipDir:   func(string) bool { return false },
+		onCreate:  func(string) {},
+		onWrite:   func(string) {},
+		roots:     map[string]*polledRoot{},
+	}
+}
+
+// configure changes interval and recursive (config reload).
+func (p *poller) configure(interval time.Duration, recursive bool) {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.interval, p.recursive = interval, recursive
+}
+
+// add starts polling root. The first listing is the baseline, so files that
+// already exist are not reported (the startup scan takes care of them).
+func (p *poller) add(root string) error {
+	files, err := p.list(root)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.roots[root] = &polledRoot{files: files}
+	return nil
+}
+
+// remove stops polling root.
+func (p *poller) remove(root string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.roots, root)
+}
+
+// has reports whether root is polled.
+func (p *poller) has(root string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.roots[root]
+	return ok
+}
+
+// run polls all roots every interval until ctx is cancelled.
+func (p *poller) run(ctx context.Context) {
+	timer := time.NewTimer(p.currentInterval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			p.pollAll()
+			timer.Reset(p.currentInterval())
+		}
+	}
+}
+
+func (p *poller) currentInterval() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.interval
+}
+
+func (p *poller) pollAll() {
+	p.mu.Lock()
+	roots := make([]string, 0, len(p.roots))
+	for root := range p.roots {
+		roots = append(roots, root)
+	}
+	p.mu.Unlock()
+	slices.Sort(roots)
+
+	for _, root := range roots {
+		p.poll(root)
+	}
+}
+
+// poll lists root once and reports the differences to the previous listing.
+// Removed files need no report: the stability detector and the queue workers
+// notice them on their own.
+func (p *poller) poll(root string) {
+	files, err := p.list(root)
+
+	p.mu.Lock()
+	r, ok := p.roots[root]
+	if !ok {
+		// Removed while listing
+		p.mu.Unlock()
+		return
+	}
+	if err != nil {
+		// Keep the previous listing so that nothing is reported twice once the
+		// share is reachable again
+		if !r.failing {
+			log.Printf("⚠️ ポーリングに失敗しました: %s -> %v", root, err)
+			r.failing = true
+		}
+		p.mu.Unlock()
+		return
+	}
+	if r.failing {
+		log.Printf("ポーリングを再開しました: %s", root)
+		r.failing = false
+	}
+	prev := r.files
+	r.files = files
+	p.mu.Unlock()
+
+	var created, written []string
+	for path, cur := range files {
+		old, ok := prev[path]
+		switch {
+		case !ok:
+			created = append(created, path)
+		case cur.size != old.size || !cur.modTime.Equal(old.modTime):
+			written = append(written, path)
+		}
+	}
+	slices.Sort(created)
+	for _, path := range created {
+		p.onCreate(path)
+	}
+	for _, path := range written {
+		p.onWrite(path)
+	}
+}
+
+// list returns size and mtime of the files below root (only the top level
+// unless recursive). Unreadable subdirectories are skipped.
+func (p *poller) list(root string) (map[string]fileStamp, error) {
+	p.mu.Lock()
+	recursive := p.recursive
+	p.mu.Unlock()
+
+	files := map[string]fileStamp{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && (!recursive || p.skipDir(path)) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files[path] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+// backendFor decides whether root is watched with fsnotify or polled: pollDirs
+// and watchBackend "poll" always poll, "auto" polls on network and FUSE
+// filesystems. The reason is logged by the caller.
+func (w *Watcher) backendFor(root string) (backend, reason string) {
+	cfg := w.conf()
+	for _, dir := range cfg.PollDirs {
+		abs, err := filepath.Abs(expandHome(dir))
+		if err != nil {
+			continue
+		}
+		if ok, _ := doublestar.PathMatch(abs, root); ok || abs == root {
+			return backendPoll, "pollDirs"
+		}
+	}
+	switch cfg.WatchBackend {
+	case backendPoll:
+		return backendPoll, "watchBackend"
+	case backendFsnotify:
+		return backendFsnotify, ""
+	}
+	if fsType := networkFSType(root); fsType != "" {
+		return backendPoll, fsType
+	}
+	return backendFsnotify, ""
+}
+
+// checkBackend validates config watchBackend.
+func checkBackend(backend string) error {
+	switch backend {
+	case "", backendAuto, backendFsnotify, backendPoll:
+		return nil
+	}
+	return fmt.Errorf("watchBackend の指定が不正です (auto, fsnotify, poll): %s", backend)
+}
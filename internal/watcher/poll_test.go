@@ -0,0 +1,104 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/config"
+)
+
+func TestPoller(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "sub", "skip"), 0755)
+	existing := filepath.Join(root, "existing.mov")
+	os.WriteFile(existing, []byte("x"), 0644)
+
+	var created, written []string
+	p := newPoller(time.Second, true)
+	p.skipDir = func(dir string) bool { return filepath.Base(dir) == "skip" }
+	p.onCreate = func(path string) { created = append(created, path) }
+	p.onWrite = func(path string) { written = append(written, path) }
+	if err := p.add(root); err != nil {
+		t.Fatal(err)
+	}
+
+	p.pollAll()
+	if len(created) != 0 || len(written) != 0 {
+		t.Fatalf("baseline reported files: created=%v written=%v", created, written)
+	}
+
+	newFile := filepath.Join(root, "sub", "new.mov")
+	os.WriteFile(newFile, []byte("x"), 0644)
+	os.WriteFile(filepath.Join(root, "sub", "skip", "ignored.mov"), []byte("x"), 0644)
+	os.WriteFile(existing, []byte("xx"), 0644)
+	p.pollAll()
+	if !slices.Equal(created, []string{newFile}) {
+		t.Errorf("created = %v, want %v", created, []string{newFile})
+	}
+	if !slices.Equal(written, []string{existing}) {
+		t.Errorf("written = %v, want %v", written, []string{existing})
+	}
+
+	// Unchanged files are not reported again
+	created, written = nil, nil
+	p.pollAll()
+	if len(created) != 0 || len(written) != 0 {
+		t.Errorf("unchanged tree reported files: created=%v written=%v", created, written)
+	}
+}
+
+func TestPollerUnreachableRoot(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "share")
+	os.MkdirAll(root, 0755)
+	os.WriteFile(filepath.Join(root, "a.mov"), []byte("x"), 0644)
+
+	var created []string
+	p := newPoller(time.Second, false)
+	p.onCreate = func(path string) { created = append(created, path) }
+	if err := p.add(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// An unmounted share keeps its last listing, so nothing is reported twice
+	hidden := root + ".offline"
+	os.Rename(root, hidden)
+	p.pollAll()
+	if !p.roots[root].failing {
+		t.Error("failed listing not recorded")
+	}
+	os.Rename(hidden, root)
+	p.pollAll()
+	if p.roots[root].failing || len(created) != 0 {
+		t.Errorf("failing=%v created=%v after the share came back", p.roots[root].failing, created)
+	}
+}
+
+func TestBackendFor(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		cfg  config.Config
+		want string
+	}{
+		{config.Config{}, backendFsnotify},
+		{config.Config{WatchBackend: "fsnotify"}, backendFsnotify},
+		{config.Config{WatchBackend: "poll"}, backendPoll},
+		{config.Config{WatchBackend: "fsnotify", PollDirs: []string{root}}, backendPoll},
+		{config.Config{PollDirs: []string{filepath.Join(root, "other")}}, backendFsnotify},
+	}
+	for _, tt := range tests {
+		if networkFSType(root) != "" {
+			t.Skip("temp dir is on a network filesystem")
+		}
+		w := &Watcher{Cfg: &tt.cfg}
+		if got, _ := w.backendFor(root); got != tt.want {
+			t.Errorf("backendFor(%+v) = %s, want %s", tt.cfg, got, tt.want)
+		}
+	}
+
+	if err := checkBackend("inotify"); err == nil {
+		t.Error("invalid watchBackend accepted")
+	}
+}
@@ -0,0 +1,352 @@
+package watcher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JobState is the state of a watch-mode job in the queue journal.
+type JobState string
+
+const (
+	JobQueued  JobState = "queued"
+	JobRunning JobState = "running"
+	JobDone    JobState = "done"
+	JobFailed  JobState = "failed"
+)
+
+// Finished jobs are kept in the journal this long after their source is gone or
+// replaced; while the same version of the source exists they are kept, so that
+// scans never convert it again.
+const journalRetention = 30 * 24 * time.Hour
+
+// A failed file is queued again by scans at most failedRetryMax times, the first
+// retry failedRetryBackoff after the failure and each further one twice as late.
+const (
+	failedRetryMax     = 5
+	failedRetryBackoff = time.Minute
+)
+
+// The journal is compacted while running once it has more than this many lines
+// and twice as many lines as jobs.
+const compactMinLines = 1000
+
+// Job is one detected file. Size and ModTime identify the version of the file
+// that was queued, so a new recording under the same name is converted again.
+type Job struct {
+	Path     string    `json:"path"`
+	State    JobState  `json:"state"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	Output   string    `json:"output,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Failures int       `json:"failures,omitempty"` // failed runs of this version in a row
+	Queued   time.Time `json:"queued"`
+	Updated  time.Time `json:"updated"`
+}
+
+// sourceExists reports whether the file the job was queued for still exists
+// in the same version.
+func (job *Job) sourceExists() bool {
+	info, err := os.Stat(job.Path)
+	return err == nil && info.Size() == job.Size && info.ModTime().Equal(job.ModTime)
+}
+
+// Queue is the durable job queue of watch mode. Every state change is appended
+// to a journal file (one JSON job per line, the last line per path wins), so that
+// queued jobs and jobs interrupted by a crash or reboot are replayed on startup.
+type Queue struct {
+	path string
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	pending []string // queued paths in FIFO order
+	journal *os.File
+	lines   int // lines in the journal, see compactMinLines
+	wake    chan struct{}
+}
+
+// OpenQueue loads the journal at path, compacts it and requeues unfinished jobs.
+// The journal is compacted again whenever it has grown well beyond the jobs.
+func OpenQueue(path string) (*Queue, error) {
+	q := &Queue{path: path, jobs: map[string]*Job{}, wake: make(chan struct{}, 1)}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+
+	// Jobs that were running when the process died start over
+	var replay []*Job
+	for _, job := range q.jobs {
+		if job.State == JobQueued || job.State == JobRunning {
+			job.State = JobQueued
+			replay = append(replay, job)
+		}
+	}
+	sort.Slice(replay, func(i, j int) bool { return replay[i].Queued.Before(replay[j].Queued) })
+	for _, job := range replay {
+		q.pending = append(q.pending, job.Path)
+	}
+	if len(replay) > 0 {
+		log.Printf("♻️ 前回の未完了ジョブを再開します: %d件", len(replay))
+	}
+
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	q.journal = f
+	q.signal()
+	return q, nil
+}
+
+func (q *Queue) load() error {
+	f, err := os.Open(q.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var job Job
+		if err := json.Unmarshal(sc.Bytes(), &job); err != nil || job.Path == "" {
+			// A line cut off by a crash; everything before it is intact
+			continue
+		}
+		q.jobs[job.Path] = &job
+	}
+	return sc.Err()
+}
+
+// compact rewrites the journal with the current state of every job, dropping
+// finished jobs older than journalRetention whose source is gone or replaced.
+func (q *Queue) compact() error {
+	tmp := q.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for path, job := range q.jobs {
+		if (job.State == JobDone || job.State == JobFailed) && time.Since(job.Updated) > journalRetention && !job.sourceExists() {
+			delete(q.jobs, path)
+			continue
+		}
+		if err := enc.Encode(job); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return err
+	}
+	q.lines = len(q.jobs)
+	return nil
+}
+
+// compactLocked compacts the journal of a running queue and reopens it for
+// appending. q.mu must be held.
+func (q *Queue) compactLocked() {
+	q.journal.Close()
+	q.journal = nil
+	if err := q.compact(); err != nil {
+		log.Printf("⚠️ ジョブ履歴の整理に失敗: %v", err)
+	}
+	f, err := os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		log.Printf("⚠️ ジョブ履歴を開けません (以降のジョブは再起動時に再開されません): %v", err)
+		return
+	}
+	q.journal = f
+}
+
+// appendLocked writes the job's new state to the journal. q.mu must be held.
+func (q *Queue) appendLocked(job *Job) {
+	job.Updated = time.Now()
+	if q.journal == nil {
+		return
+	}
+	data, err := json.Marshal(job)
+	if err == nil {
+		_, err = q.journal.Write(append(data, '\n'))
+	}
+	if err == nil {
+		err = q.journal.Sync()
+	}
+	if err != nil {
+		log.Printf("⚠️ ジョブ履歴の書き込みに失敗: %v", err)
+		return
+	}
+	q.lines++
+	if q.lines > max(compactMinLines, 2*len(q.jobs)) {
+		q.compactLocked()
+	}
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Enqueue adds path unless it is already queued or running. It reports whether
+// a job was added.
+func (q *Queue) Enqueue(path string) bool {
+	var size int64
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		size, modTime = info.Size(), info.ModTime()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	prev, ok := q.jobs[path]
+	if ok && (prev.State == JobQueued || prev.State == JobRunning) {
+		return false
+	}
+	job := &Job{Path: path, State: JobQueued, Size: size, ModTime: modTime, Queued: time.Now()}
+	if ok && prev.State == JobFailed && prev.Size == size && prev.ModTime.Equal(modTime) {
+		// A retry of the same version: keep counting towards failedRetryMax
+		job.Failures = prev.Failures
+	}
+	q.jobs[path] = job
+	q.pending = append(q.pending, path)
+	q.appendLocked(job)
+	q.signal()
+	return true
+}
+
+// Next blocks until a job is queued, marks it running and returns it.
+func (q *Queue) Next(ctx context.Context) (Job, error) {
+	for {
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			path := q.pending[0]
+			q.pending = q.pending[1:]
+			job := q.jobs[path]
+			job.State = JobRunning
+			q.appendLocked(job)
+			if len(q.pending) > 0 {
+				q.signal()
+			}
+			q.mu.Unlock()
+			return *job, nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.wake:
+		case <-ctx.Done():
+			return Job{}, ctx.Err()
+		}
+	}
+}
+
+// Finish records the outcome of a running job.
+func (q *Queue) Finish(path, output string, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[path]
+	if !ok {
+		return
+	}
+	if err != nil {
+		job.State, job.Error = JobFailed, err.Error()
+		job.Failures++
+	} else {
+		job.State, job.Output, job.Error, job.Failures = JobDone, output, "", 0
+	}
+	q.appendLocked(job)
+}
+
+// MarkDone records path as converted to output without running a job (a
+// conversion from before the journal existed).
+func (q *Queue) MarkDone(path, output string) {
+	var size int64
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		size, modTime = info.Size(), info.ModTime()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.jobs[path]; ok {
+		return
+	}
+	job := &Job{Path: path, State: JobDone, Size: size, ModTime: modTime, Output: output, Queued: time.Now()}
+	q.jobs[path] = job
+	q.appendLocked(job)
+}
+
+// Requeue puts a running job back (e.g. it was interrupted by shutdown).
+func (q *Queue) Requeue(path string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job, ok := q.jobs[path]; ok && job.State == JobRunning {
+		job.State = JobQueued
+		q.appendLocked(job)
+	}
+}
+
+// retryDue reports whether a failed job may be queued again by a scan (see
+// failedRetryMax).
+func (job *Job) retryDue(now time.Time) bool {
+	failures := max(job.Failures, 1) // journals written before failures were counted
+	if failures >= failedRetryMax {
+		return false
+	}
+	return now.Sub(job.Updated) >= failedRetryBackoff<<(failures-1)
+}
+
+// Get returns the recorded job for path.
+func (q *Queue) Get(path string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[path]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Close closes the journal. Unfinished jobs are replayed by the next OpenQueue.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.journal == nil {
+		return nil
+	}
+	err := q.journal.Close()
+	q.journal = nil
+	return err
+}
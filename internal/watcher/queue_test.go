@@ -0,0 +1,186 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueueReplay(t *testing.T) {
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "state", "watch-queue.jsonl")
+
+	q, err := OpenQueue(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.mov", "b.mov", "c.mov"} {
+		if !q.Enqueue(filepath.Join(dir, name)) {
+			t.Fatalf("Enqueue(%s) = false", name)
+		}
+	}
+	if q.Enqueue(filepath.Join(dir, "a.mov")) {
+		t.Error("a queued file must not be added twice")
+	}
+
+	ctx := context.Background()
+	a, _ := q.Next(ctx)
+	q.Finish(a.Path, "/out/a.mp4", nil)
+	b, _ := q.Next(ctx) // running when the process "crashes"
+	if a.Path != filepath.Join(dir, "a.mov") || b.Path != filepath.Join(dir, "b.mov") {
+		t.Fatalf("jobs out of order: %s, %s", a.Path, b.Path)
+	}
+	q.Close()
+
+	// Reopen: b (running) and c (queued) are replayed in order, a is done
+	q, err = OpenQueue(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if job, _ := q.Get(a.Path); job.State != JobDone || job.Output != "/out/a.mp4" {
+		t.Errorf("a = %+v, want done", job)
+	}
+	for _, want := range []string{"b.mov", "c.mov"} {
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		job, err := q.Next(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("replay %s: %v", want, err)
+		}
+		if filepath.Base(job.Path) != want || job.State != JobRunning {
+			t.Errorf("replayed %+v, want %s running", job, want)
+		}
+	}
+
+	// Nothing else is pending
+	ctx2, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Next(ctx2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Next on empty queue = %v", err)
+	}
+
+	// The journal was compacted: one line per job plus the two replays
+	data, _ := os.ReadFile(journal)
+	if n := strings.Count(string(data), "\n"); n != 5 {
+		t.Errorf("journal has %d lines, want 5:\n%s", n, data)
+	}
+}
+
+func TestQueueFailedAndRequeue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenQueue(filepath.Join(dir, "queue.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	path := filepath.Join(dir, "a.mov")
+	ctx := context.Background()
+
+	q.Enqueue(path)
+	job, _ := q.Next(ctx)
+	q.Finish(job.Path, "", errors.New("boom"))
+	if got, _ := q.Get(path); got.State != JobFailed || got.Error != "boom" {
+		t.Errorf("job = %+v, want failed", got)
+	}
+
+	// A failed file can be queued again (e.g. a new event for it)
+	if !q.Enqueue(path) {
+		t.Fatal("failed job must be queueable again")
+	}
+	job, _ = q.Next(ctx)
+	q.Requeue(job.Path)
+	if got, _ := q.Get(path); got.State != JobQueued {
+		t.Errorf("requeued job = %+v", got)
+	}
+}
+
+func TestQueueSkipsTruncatedLine(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "queue.jsonl")
+	data := `{"path":"/rec/a.mov","state":"queued","size":1,"mod_time":"2026-01-01T00:00:00Z","updated":"2026-01-01T00:00:00Z"}
+{"path":"/rec/b.mov","sta`
+	if err := os.WriteFile(journal, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	q, err := OpenQueue(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if _, ok := q.Get("/rec/a.mov"); !ok {
+		t.Error("intact job lost")
+	}
+	if _, ok := q.Get("/rec/b.mov"); ok {
+		t.Error("truncated job must be dropped")
+	}
+}
+
+func TestQueueKeepsExistingSources(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "kept.mov") // noTrash: the source stays
+	os.WriteFile(kept, []byte("x"), 0644)
+	old := time.Now().Add(-2 * journalRetention)
+	os.Chtimes(kept, old, old)
+
+	line := func(path string) string {
+		job := Job{Path: path, State: JobDone, Size: 1, ModTime: old, Queued: old, Updated: old}
+		data, _ := json.Marshal(job)
+		return string(data) + "\n"
+	}
+	journal := filepath.Join(dir, "queue.jsonl")
+	os.WriteFile(journal, []byte(line(kept)+line(filepath.Join(dir, "gone.mov"))), 0644)
+
+	q, err := OpenQueue(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if _, ok := q.Get(kept); !ok {
+		t.Error("done job of an existing source expired")
+	}
+	if _, ok := q.Get(filepath.Join(dir, "gone.mov")); ok {
+		t.Error("done job of a removed source kept past the retention")
+	}
+}
+
+func TestQueueCompactsWhileRunning(t *testing.T) {
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "watch-queue.jsonl")
+	q, err := OpenQueue(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	// The same recording converted over and over (3 lines per job)
+	path := filepath.Join(dir, "a.mov")
+	for i := 0; i < compactMinLines; i++ {
+		q.Enqueue(path)
+		job, _ := q.Next(context.Background())
+		q.Finish(job.Path, "/out/a.mp4", nil)
+	}
+
+	data, _ := os.ReadFile(journal)
+	if n := strings.Count(string(data), "\n"); n > compactMinLines {
+		t.Errorf("journal has %d lines, want at most %d", n, compactMinLines)
+	}
+	// Appending continues after the compaction
+	q.Enqueue(filepath.Join(dir, "b.mov"))
+	q.Close()
+	q, err = OpenQueue(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if job, ok := q.Get(filepath.Join(dir, "b.mov")); !ok || job.State != JobQueued {
+		t.Errorf("b = %+v, want queued after reopening", job)
+	}
+	if job, _ := q.Get(path); job.State != JobDone {
+		t.Errorf("a = %+v, want done", job)
+	}
+}
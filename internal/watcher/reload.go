@@ -0,0 +1,201 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mt4110/rec-watch/internal/config"
+)
+
+// Editors save in several steps (write, rename, chmod); they are merged into one reload
+const reloadDebounce = 500 * time.Millisecond
+
+// watchRoots returns the absolute watch dirs.
+func (w *Watcher) watchRoots() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return slices.Clone(w.roots)
+}
+
+// addRoot starts watching a watch dir with the backend chosen by backendFor and
+// returns its absolute path ("" if the path is invalid). A dir that cannot be
+// watched yet (e.g. an unmounted drive) is kept and retried like a lost one.
+func (w *Watcher) addRoot(dir string) string {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		log.Printf("⚠️ ディレクトリパスの解決に失敗 (スキップ): %s -> %v", dir, err)
+		return ""
+	}
+	w.mu.Lock()
+	if !slices.Contains(w.roots, root) {
+		w.roots = append(w.roots, root)
+	}
+	w.mu.Unlock()
+
+	if err := w.watchRoot(root); err != nil {
+		w.rootLost(root, err)
+	}
+	return root
+}
+
+// removeRoot stops watching a watch dir and the subdirectories watched with it.
+func (w *Watcher) removeRoot(root string) {
+	w.unwatchRoot(root)
+
+	w.mu.Lock()
+	w.roots = slices.DeleteFunc(w.roots, func(r string) bool { return r == root })
+	delete(w.health, root)
+	w.mu.Unlock()
+	w.writeStatus(w.status())
+}
+
+// watchReloads returns a channel that receives when ConfigPath changes or the
+// process gets SIGHUP (nil if Reload is not set).
+func (w *Watcher) watchReloads(ctx context.Context) <-chan struct{} {
+	if w.Reload == nil {
+		return nil
+	}
+	reloads := make(chan struct{}, 1)
+	trigger := func() {
+		select {
+		case reloads <- struct{}{}:
+		default:
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	// The directory is watched, as editors replace the file instead of writing it
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	var cfw *fsnotify.Watcher
+	if w.ConfigPath != "" {
+		var err error
+		cfw, err = fsnotify.NewWatcher()
+		if err == nil {
+			err = cfw.Add(filepath.Dir(w.ConfigPath))
+		}
+		if err != nil {
+			log.Printf("⚠️ 設定ファイルの監視を開始できません (SIGHUP で再読み込みできます): %v", err)
+			if cfw != nil {
+				cfw.Close()
+				cfw = nil
+			}
+		} else {
+			events, errs = cfw.Events, cfw.Errors
+		}
+	}
+
+	w.jobs.Add(1)
+	go func() {
+		defer w.jobs.Done()
+		defer signal.Stop(hup)
+		if cfw != nil {
+			defer cfw.Close()
+		}
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				log.Println("🔄 SIGHUP を受信しました")
+				trigger()
+			case event, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				if filepath.Clean(event.Name) == filepath.Clean(w.ConfigPath) && !event.Has(fsnotify.Chmod) {
+					debounce = time.After(reloadDebounce)
+				}
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				log.Println("設定ファイルの監視エラー:", err)
+			case <-debounce:
+				debounce = nil
+				trigger()
+			}
+		}
+	}()
+	return reloads
+}
+
+// reload reads the config again and applies it to subsequent jobs: filters,
+// profiles and encode settings, destDir, concurrency and the watched
+// directories. Running jobs finish with the config they started with. An
+// invalid config is rejected as a whole and the current one is kept.
+func (w *Watcher) reload(ctx context.Context) {
+	next, set, err := w.loadConfig()
+	if err != nil {
+		log.Printf("❌ 設定の再読み込みに失敗しました (現在の設定を維持します): %v", err)
+		return
+	}
+	prev := w.conf()
+	changes := config.Diff(prev, next)
+	if len(changes) == 0 {
+		log.Println("🔄 設定を再読み込みしました (変更なし)")
+		return
+	}
+	cvt, err := w.converter().WithConfig(next)
+	if err != nil {
+		log.Printf("❌ 設定の再読み込みに失敗しました (現在の設定を維持します): %v", err)
+		return
+	}
+
+	w.mu.Lock()
+	w.Cfg, w.Converter, w.settings = next, cvt, set
+	w.mu.Unlock()
+
+	log.Println("🔄 設定を再読み込みしました:")
+	for _, c := range changes {
+		log.Printf("   %s", c)
+	}
+	if prev.StatePath() != next.StatePath() || prev.LogFile != next.LogFile {
+		log.Println("⚠️ stateDir と logFile の変更は再起動後に反映されます")
+	}
+
+	w.stable.configure(set.stableWindow, next.CheckMoov)
+	w.poll.configure(set.pollInterval, next.Recursive)
+	w.setWorkers(ctx, w.workerCount())
+	// Trees are watched again when options that decide how they are watched changed
+	w.syncRoots(prev.Recursive != next.Recursive ||
+		prev.WatchBackend != next.WatchBackend ||
+		prev.DestDir != next.DestDir ||
+		!slices.Equal(prev.IgnoreDirs, next.IgnoreDirs) ||
+		!slices.Equal(prev.PollDirs, next.PollDirs))
+	if prev.ScanInterval != next.ScanInterval || prev.NoScan != next.NoScan {
+		w.startScans(ctx)
+	}
+
+	w.emit(ConfigReloadedEvent{WatchDirs: w.watchRoots(), Changes: changes})
+}
+
+// loadConfig calls Reload and validates the watch-mode options of the result.
+func (w *Watcher) loadConfig() (*config.Config, settings, error) {
+	next, err := w.Reload()
+	if err != nil {
+		return nil, settings{}, err
+	}
+	if len(next.WatchDirs) == 0 {
+		return nil, settings{}, fmt.Errorf("監視対象のディレクトリが設定されていません")
+	}
+	set, err := parseSettings(next)
+	if err != nil {
+		return nil, settings{}, err
+	}
+	return next, set, nil
+}
@@ -0,0 +1,100 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/mt4110/rec-watch/internal/config"
+	"github.com/mt4110/rec-watch/internal/convert"
+)
+
+func newReloadTest(t *testing.T, cfg *config.Config) *Watcher {
+	t.Helper()
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fsw.Close() })
+	q, err := OpenQueue(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+
+	w := New(cfg, convert.New(cfg))
+	w.fsw, w.queue = fsw, q
+	w.stable = newStabilityDetector(0, false)
+	w.poll = newPoller(0, false)
+	w.settings, err = parseSettings(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestReload(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(dirB, "existing.mov"), []byte("x"), 0644)
+
+	cfg := config.NewDefault()
+	cfg.WatchDirs = []string{dirA}
+	cfg.DestDir = t.TempDir()
+	cfg.StateDir = t.TempDir()
+	cfg.Concurrent = 1
+	w := newReloadTest(t, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		w.jobs.Wait()
+	}()
+	w.setWorkers(ctx, w.workerCount())
+	w.addRoot(dirA)
+
+	next := *cfg
+	next.WatchDirs = []string{dirB}
+	next.Keywords = []string{"existing"}
+	next.Concurrent = 3
+	w.Reload = func() (*config.Config, error) { return &next, nil }
+	w.reload(ctx)
+
+	if w.conf() != &next {
+		t.Fatal("config not replaced")
+	}
+	if got := w.watchRoots(); !slices.Equal(got, []string{dirB}) {
+		t.Errorf("roots = %v, want %v", got, []string{dirB})
+	}
+	if got := w.fsw.WatchList(); !slices.Equal(got, []string{dirB}) {
+		t.Errorf("fsnotify watches = %v, want %v", got, []string{dirB})
+	}
+	if !w.stable.isPending(filepath.Join(dirB, "existing.mov")) {
+		t.Error("file in the added directory not detected")
+	}
+	if got := w.converter().Sched.Slots(); got != 3 {
+		t.Errorf("scheduler slots = %d, want 3", got)
+	}
+	if len(w.workers) != 3 {
+		t.Errorf("queue workers = %d, want 3", len(w.workers))
+	}
+
+	// An invalid config is rejected as a whole
+	bad := next
+	bad.WatchDirs = []string{dirA}
+	bad.StableWindow = "soon"
+	w.Reload = func() (*config.Config, error) { return &bad, nil }
+	w.reload(ctx)
+	if w.conf() != &next || !slices.Equal(w.watchRoots(), []string{dirB}) {
+		t.Error("invalid config was applied")
+	}
+	bad = next
+	bad.Codec = "h266"
+	w.reload(ctx)
+	if w.conf() != &next {
+		t.Error("config rejected by the converter was applied")
+	}
+}
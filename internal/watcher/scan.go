@@ -0,0 +1,127 @@
+package watcher
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// scan looks for videos that arrived while the watcher was not running (or whose
+// events were missed) and hands them to the stability detector like new files.
+// Files already queued, running or converted in the same version (size and mtime
+// recorded in the queue journal) are skipped; failed ones are retried with a
+// backoff (see failedRetryMax). It returns the number of files found.
+func (w *Watcher) scan(roots []string) int {
+	recursive := w.conf().Recursive
+	found := 0
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if d != nil && d.IsDir() && path != root {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if path == root {
+					return nil
+				}
+				if !recursive || w.ignoredDir(path) {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if w.scanCandidate(path, d) {
+				w.detect(path)
+				found++
+			}
+			return nil
+		})
+	}
+	return found
+}
+
+// scanCandidate applies the filters of detect without logging skipped files,
+// and checks the journal for an earlier run on the same version of the file.
+// A file the journal does not know is skipped if its output already exists
+// (converted before the journal recorded it); it is recorded as done then.
+func (w *Watcher) scanCandidate(path string, d fs.DirEntry) bool {
+	name := d.Name()
+	if strings.HasPrefix(name, ".") || !w.isTargetVideo(name) {
+		return false
+	}
+	if ok, _ := w.keywordFilter(name); !ok {
+		return false
+	}
+	if w.stable.isPending(path) {
+		return false
+	}
+
+	job, ok := w.queue.Get(path)
+	if !ok {
+		if out := w.converter().ExistingOutput(path); out != "" {
+			log.Printf("⏭ 変換済みの出力があるためスキップします: %s -> %s", path, out)
+			w.queue.MarkDone(path, out)
+			return false
+		}
+		return true
+	}
+	switch job.State {
+	case JobQueued, JobRunning:
+		return false
+	}
+	info, err := d.Info()
+	if err != nil {
+		return false
+	}
+	// Only a new version of a converted file is converted again
+	if info.Size() != job.Size || !info.ModTime().Equal(job.ModTime) {
+		return true
+	}
+	if job.State == JobFailed && job.retryDue(time.Now()) {
+		log.Printf("🔁 変換に失敗したファイルを再試行します (%d回目): %s", max(job.Failures, 1)+1, path)
+		return true
+	}
+	return false
+}
+
+// startScans (re)starts the periodic scan with the current scanInterval.
+func (w *Watcher) startScans(ctx context.Context) {
+	if w.stopScans != nil {
+		w.stopScans()
+		w.stopScans = nil
+	}
+	w.mu.RLock()
+	interval, noScan := w.settings.scanInterval, w.Cfg.NoScan
+	w.mu.RUnlock()
+	if interval <= 0 || noScan {
+		return
+	}
+
+	scanCtx, stop := context.WithCancel(ctx)
+	w.stopScans = stop
+	w.jobs.Add(1)
+	go func() {
+		defer w.jobs.Done()
+		w.runScans(scanCtx, interval)
+	}()
+}
+
+// runScans repeats the catch-up scan every interval.
+func (w *Watcher) runScans(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := w.scan(w.watchRoots()); n > 0 {
+				log.Printf("🔎 定期スキャンで未変換のファイルを %d件 見つけました", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mt4110/rec-watch/internal/config"
+	"github.com/mt4110/rec-watch/internal/convert"
+)
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "sub"), 0755)
+	write := func(name, data string) string {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("new.mov", "x")
+	old := write("old.mov", "x") // converted before the journal existed
+	done := write("done.mov", "x")
+	changed := write("changed.mov", "x")
+	failed := write("failed.mov", "x")
+	write("draft_skip.mov", "x")
+	write("notes.txt", "x")
+	write(".hidden.mov", "x")
+	write("sub/deep.mov", "x")
+
+	q, err := OpenQueue(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	for _, path := range []string{done, changed, failed} {
+		q.Enqueue(path)
+		job, _ := q.Next(context.Background())
+		var err error
+		if path == failed {
+			err = errors.New("boom")
+		}
+		q.Finish(job.Path, "", err)
+	}
+	// changed.mov was re-recorded after its conversion
+	write("changed.mov", "xx")
+
+	cfg := &config.Config{IgnoreKeywords: []string{"skip"}, DestDir: t.TempDir(), NameTemplate: "{stem}.{ext}"}
+	cvt := convert.New(cfg)
+	os.MkdirAll(filepath.Join(cfg.DestDir, "2024-01-01_10-00-00"), 0755)
+	os.WriteFile(filepath.Join(cfg.DestDir, "2024-01-01_10-00-00", "old.mp4"), []byte("x"), 0644)
+	w := &Watcher{Cfg: cfg, Converter: cvt, queue: q, stable: newStabilityDetector(0, false)}
+
+	pendingNames := func() []string {
+		var names []string
+		for path := range w.stable.pending {
+			rel, _ := filepath.Rel(root, path)
+			names = append(names, rel)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	if n := w.scan([]string{root}); n != 2 {
+		t.Errorf("scan found %d files, want 2 (%v)", n, pendingNames())
+	}
+	got := pendingNames()
+	if len(got) != 2 || got[0] != "changed.mov" || got[1] != "new.mov" {
+		t.Errorf("pending = %v", got)
+	}
+	if job, _ := q.Get(old); job.State != JobDone {
+		t.Errorf("old.mov = %+v, want recorded as done", job)
+	}
+
+	// Already pending files are not found twice; recursive adds the subfolder
+	cfg.Recursive = true
+	if n := w.scan([]string{root}); n != 1 {
+		t.Errorf("recursive rescan found %d files, want 1", n)
+	}
+	if _, ok := w.stable.pending[filepath.Join(root, "sub", "deep.mov")]; !ok {
+		t.Error("sub/deep.mov not found by the recursive scan")
+	}
+
+	// A failed file is retried once its backoff has passed
+	q.mu.Lock()
+	q.jobs[failed].Updated = time.Now().Add(-failedRetryBackoff)
+	q.mu.Unlock()
+	if n := w.scan([]string{root}); n != 1 || !w.stable.isPending(failed) {
+		t.Fatalf("rescan found %d files, want failed.mov retried", n)
+	}
+	w.enqueue(failed)
+	job, _ := q.Next(context.Background())
+	if job.Path != failed || job.Failures != 1 {
+		t.Fatalf("retried job = %+v, want failed.mov with 1 failure", job)
+	}
+
+	// ... but not after failedRetryMax failures in a row
+	for i := 1; i < failedRetryMax; i++ {
+		q.Finish(failed, "", errors.New("boom"))
+		q.Enqueue(failed)
+		q.Next(context.Background())
+	}
+	q.Finish(failed, "", errors.New("boom"))
+	q.mu.Lock()
+	q.jobs[failed].Updated = time.Now().Add(-24 * time.Hour)
+	q.mu.Unlock()
+	delete(w.stable.pending, failed)
+	if w.scan([]string{root}) != 0 {
+		job, _ := q.Get(failed)
+		t.Errorf("failed.mov retried after %d failures: %+v", failedRetryMax, job)
+	}
+}
@@ -0,0 +1,235 @@
+package watcher
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default of stableWindow: how long size and mtime must stay unchanged
+const defaultStableWindow = 3 * time.Second
+
+// stabilityDetector holds detected files until they are completely written: their
+// size and mtime have not changed for window (and, with checkMoov, MP4/MOV files
+// already contain their moov atom, which recorders write last).
+type stabilityDetector struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	window    time.Duration
+	checkMoov bool
+	pending   map[string]*pendingFile
+}
+
+type pendingFile struct {
+	size        int64
+	modTime     time.Time
+	since       time.Time // last change seen
+	moovWaiting bool      // logged that the moov atom is missing
+}
+
+func newStabilityDetector(window time.Duration, checkMoov bool) *stabilityDetector {
+	if window <= 0 {
+		window = defaultStableWindow
+	}
+	return &stabilityDetector{window: window, checkMoov: checkMoov, now: time.Now, pending: map[string]*pendingFile{}}
+}
+
+// configure changes window and checkMoov (config reload).
+func (d *stabilityDetector) configure(window time.Duration, checkMoov bool) {
+	if window <= 0 {
+		window = defaultStableWindow
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.window, d.checkMoov = window, checkMoov
+}
+
+// add starts (or restarts) waiting for path.
+func (d *stabilityDetector) add(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending[path] = &pendingFile{size: -1, since: d.now()}
+}
+
+// isPending reports whether path is waiting to become stable.
+func (d *stabilityDetector) isPending(path string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.pending[path]
+	return ok
+}
+
+// touch restarts the window of a pending file (Write events). Files that are
+// not pending are ignored.
+func (d *stabilityDetector) touch(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if p, ok := d.pending[path]; ok {
+		p.since = d.now()
+	}
+}
+
+// check stats the pending files and returns the ones that are ready. The file
+// I/O runs without holding mu, so event handling is not blocked by a slow disk.
+func (d *stabilityDetector) check() []string {
+	type probed struct {
+		path    string
+		p       *pendingFile
+		size    int64
+		modTime time.Time
+		due     bool // unchanged for window when snapshotted
+		info    os.FileInfo
+		err     error
+		checked bool // moov atom looked up
+		moovOK  bool
+	}
+
+	// Snapshot under the lock: touch may change the entries meanwhile
+	d.mu.Lock()
+	now := d.now()
+	window, checkMoov := d.window, d.checkMoov
+	files := make([]probed, 0, len(d.pending))
+	for path, p := range d.pending {
+		files = append(files, probed{path: path, p: p, size: p.size, modTime: p.modTime, due: now.Sub(p.since) >= window})
+	}
+	d.mu.Unlock()
+
+	for i := range files {
+		f := &files[i]
+		f.info, f.err = os.Stat(f.path)
+		if f.err != nil || !f.due || !checkMoov || !needsMoov(f.path) {
+			continue
+		}
+		if f.info.Size() != f.size || !f.info.ModTime().Equal(f.modTime) {
+			continue
+		}
+		ok, err := hasMoov(f.path)
+		f.checked, f.moovOK = true, err == nil && ok
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var ready []string
+	for _, f := range files {
+		p := f.p
+		if d.pending[f.path] != p {
+			// Removed or restarted by add while unlocked
+			continue
+		}
+		if f.err != nil {
+			log.Printf("ファイルが見つかりません (削除または移動されました): %s", f.path)
+			delete(d.pending, f.path)
+			continue
+		}
+		if f.info.Size() != p.size || !f.info.ModTime().Equal(p.modTime) {
+			p.size, p.modTime, p.since = f.info.Size(), f.info.ModTime(), now
+			continue
+		}
+		if now.Sub(p.since) < window {
+			continue
+		}
+		if checkMoov && needsMoov(f.path) {
+			if !f.checked {
+				// Not looked up in this round; checked next time
+				continue
+			}
+			if !f.moovOK {
+				if !p.moovWaiting {
+					log.Printf("⏳ 書き込み完了を待っています (moov atom がまだありません): %s", filepath.Base(f.path))
+					p.moovWaiting = true
+				}
+				continue
+			}
+		}
+		ready = append(ready, f.path)
+		delete(d.pending, f.path)
+	}
+	return ready
+}
+
+// run checks the pending files periodically and calls onReady for finished ones.
+func (d *stabilityDetector) run(ctx context.Context, onReady func(path string)) {
+	timer := time.NewTimer(d.checkInterval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			for _, path := range d.check() {
+				onReady(path)
+			}
+			timer.Reset(d.checkInterval())
+		}
+	}
+}
+
+func (d *stabilityDetector) checkInterval() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return min(max(d.window/4, 100*time.Millisecond), time.Second)
+}
+
+func needsMoov(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".mov", ".m4v":
+		return true
+	}
+	return false
+}
+
+// hasMoov walks the top-level boxes of an ISO BMFF (MP4/MOV) file and reports
+// whether a moov box is present and complete.
+func hasMoov(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	fileSize := info.Size()
+
+	var offset int64
+	header := make([]byte, 16)
+	for offset+8 <= fileSize {
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			return false, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		headerLen := int64(8)
+		switch size {
+		case 0:
+			// Box extends to the end of the file
+			size = fileSize - offset
+		case 1:
+			if _, err := f.ReadAt(header[8:16], offset+8); err != nil {
+				if err == io.EOF {
+					return false, nil
+				}
+				return false, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerLen = 16
+		}
+		if size < headerLen {
+			return false, fmt.Errorf("不正なボックスサイズ: %s @%d", boxType, offset)
+		}
+		if boxType == "moov" {
+			return offset+size <= fileSize, nil
+		}
+		offset += size
+	}
+	return false, nil
+}
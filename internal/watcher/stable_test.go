@@ -0,0 +1,135 @@
+package watcher
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// box builds an ISO BMFF box with a 32-bit size.
+func box(typ string, payload int) []byte {
+	b := make([]byte, 8+payload)
+	binary.BigEndian.PutUint32(b, uint32(8+payload))
+	copy(b[4:], typ)
+	return b
+}
+
+func TestStabilityDetector(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rec.mkv")
+	if err := os.WriteFile(path, []byte("part"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	d := newStabilityDetector(3*time.Second, false)
+	d.now = func() time.Time { return now }
+
+	d.add(path)
+	if ready := d.check(); len(ready) != 0 {
+		t.Fatalf("first check must only record size/mtime: %v", ready)
+	}
+
+	// Still growing: the window restarts
+	now = now.Add(2 * time.Second)
+	if err := os.WriteFile(path, []byte("part two"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ready := d.check(); len(ready) != 0 {
+		t.Fatalf("changed file must not be ready: %v", ready)
+	}
+	now = now.Add(2 * time.Second)
+	if ready := d.check(); len(ready) != 0 {
+		t.Fatalf("ready before the window: %v", ready)
+	}
+
+	// A Write event also restarts the window
+	d.touch(path)
+	now = now.Add(2 * time.Second)
+	if ready := d.check(); len(ready) != 0 {
+		t.Fatalf("ready right after a write event: %v", ready)
+	}
+
+	now = now.Add(2 * time.Second)
+	if ready := d.check(); len(ready) != 1 || ready[0] != path {
+		t.Fatalf("ready = %v, want %s", ready, path)
+	}
+	if len(d.pending) != 0 {
+		t.Error("ready file must leave the pending list")
+	}
+
+	// Removed while pending
+	d.add(path)
+	os.Remove(path)
+	if ready := d.check(); len(ready) != 0 || len(d.pending) != 0 {
+		t.Errorf("removed file: ready=%v pending=%d", ready, len(d.pending))
+	}
+}
+
+func TestStabilityDetectorWaitsForMoov(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.mov")
+	data := append(box("ftyp", 12), box("mdat", 100)...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	d := newStabilityDetector(time.Second, true)
+	d.now = func() time.Time { return now }
+	d.add(path)
+	d.check()
+	now = now.Add(2 * time.Second)
+	if ready := d.check(); len(ready) != 0 {
+		t.Fatalf("ready without moov: %v", ready)
+	}
+
+	if err := os.WriteFile(path, append(data, box("moov", 40)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d.check()
+	now = now.Add(2 * time.Second)
+	if ready := d.check(); len(ready) != 1 {
+		t.Fatalf("ready = %v after moov was written", ready)
+	}
+}
+
+func TestHasMoov(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, parts ...[]byte) string {
+		var data []byte
+		for _, p := range parts {
+			data = append(data, p...)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// 64-bit mdat size followed by moov
+	large := make([]byte, 16+32)
+	binary.BigEndian.PutUint32(large, 1)
+	copy(large[4:], "mdat")
+	binary.BigEndian.PutUint64(large[8:], uint64(len(large)))
+
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"moov at end", write("a.mp4", box("ftyp", 12), box("mdat", 64), box("moov", 32)), true},
+		{"faststart", write("b.mp4", box("ftyp", 12), box("moov", 32), box("mdat", 64)), true},
+		{"no moov", write("c.mp4", box("ftyp", 12), box("mdat", 64)), false},
+		{"truncated moov", write("d.mp4", box("ftyp", 12), box("moov", 32)[:20]), false},
+		{"largesize mdat", write("e.mov", box("ftyp", 12), large, box("moov", 8)), true},
+	}
+	for _, tt := range tests {
+		got, _ := hasMoov(tt.path)
+		if got != tt.want {
+			t.Errorf("%s: hasMoov = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
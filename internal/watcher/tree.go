@@ -0,0 +1,117 @@
+package watcher
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// addTree watches root and, with recursive, every directory below it that is not
+// ignored (see ignoredDir). It returns the number of directories added.
+func (w *Watcher) addTree(root string) int {
+	if !w.conf().Recursive {
+		if err := w.fsw.Add(root); err != nil {
+			log.Printf("⚠️ 監視エラー (スキップ): %s -> %v", root, err)
+			return 0
+		}
+		return 1
+	}
+
+	added := 0
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("⚠️ ディレクトリを読めません (スキップ): %s -> %v", path, err)
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && w.ignoredDir(path) {
+			return fs.SkipDir
+		}
+		if err := w.fsw.Add(path); err != nil {
+			log.Printf("⚠️ 監視エラー (スキップ): %s -> %v", path, err)
+			return fs.SkipDir
+		}
+		added++
+		return nil
+	})
+	return added
+}
+
+// addNewDir starts watching a directory created (or moved) below a watched one.
+// Files it already contains produced no events, so they are detected here.
+func (w *Watcher) addNewDir(dir string) {
+	if w.ignoredDir(dir) {
+		return
+	}
+	if n := w.addTree(dir); n > 0 {
+		log.Printf("📁 サブディレクトリの監視を開始しました: %s", dir)
+	}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && w.ignoredDir(path) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		w.detect(path)
+		return nil
+	})
+}
+
+// ignoredDir reports whether a subdirectory is left out of recursive watching:
+// hidden directories, the output and state directories (converted files must not
+// be picked up again) and directories matching ignoreDirs. ignoreDirs entries are
+// doublestar patterns matched against the full path and the directory name.
+func (w *Watcher) ignoredDir(dir string) bool {
+	cfg := w.conf()
+	name := filepath.Base(dir)
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	for _, special := range []string{cfg.DestDir, cfg.StatePath()} {
+		if special == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(special); err == nil && isWithin(dir, abs) {
+			return true
+		}
+	}
+	for _, pattern := range cfg.IgnoreDirs {
+		pattern = filepath.ToSlash(expandHome(pattern))
+		if ok, _ := doublestar.Match(pattern, filepath.ToSlash(dir)); ok {
+			return true
+		}
+		if ok, _ := doublestar.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isWithin reports whether path is dir or below it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// expandHome replaces a leading ~ with the home directory.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
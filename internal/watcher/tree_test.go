@@ -0,0 +1,100 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/mt4110/rec-watch/internal/config"
+)
+
+func newTreeTest(t *testing.T, cfg *config.Config) (*Watcher, string) {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{"a/b", ".cache", "out/20260101", "node_modules/x"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fsw.Close() })
+
+	cfg.DestDir = filepath.Join(root, "out")
+	cfg.StateDir = filepath.Join(t.TempDir(), "state")
+	w := &Watcher{Cfg: cfg, fsw: fsw, stable: newStabilityDetector(0, false)}
+	return w, root
+}
+
+func TestAddTreeRecursive(t *testing.T) {
+	w, root := newTreeTest(t, &config.Config{Recursive: true, IgnoreDirs: []string{"node_modules"}})
+
+	if n := w.addTree(root); n != 3 {
+		t.Errorf("addTree = %d, want 3 (root, a, a/b)", n)
+	}
+	got := w.fsw.WatchList()
+	for _, want := range []string{root, filepath.Join(root, "a"), filepath.Join(root, "a", "b")} {
+		if !slices.Contains(got, want) {
+			t.Errorf("%s not watched (%v)", want, got)
+		}
+	}
+}
+
+func TestAddTreeNonRecursive(t *testing.T) {
+	w, root := newTreeTest(t, &config.Config{})
+	if n := w.addTree(root); n != 1 {
+		t.Errorf("addTree = %d, want only the root", n)
+	}
+}
+
+func TestAddNewDirDetectsExistingFiles(t *testing.T) {
+	w, root := newTreeTest(t, &config.Config{Recursive: true})
+
+	// A folder moved into the watched tree together with its recordings
+	dir := filepath.Join(root, "a", "session")
+	os.MkdirAll(filepath.Join(dir, "take2"), 0755)
+	for _, name := range []string{"take1.mov", "take2/cam.mp4", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w.addNewDir(dir)
+	if !slices.Contains(w.fsw.WatchList(), filepath.Join(dir, "take2")) {
+		t.Error("nested directory of the new folder is not watched")
+	}
+	for _, name := range []string{"take1.mov", "take2/cam.mp4"} {
+		if _, ok := w.stable.pending[filepath.Join(dir, name)]; !ok {
+			t.Errorf("%s not detected", name)
+		}
+	}
+	if len(w.stable.pending) != 2 {
+		t.Errorf("pending = %d files, want 2", len(w.stable.pending))
+	}
+}
+
+func TestIgnoredDir(t *testing.T) {
+	w, root := newTreeTest(t, &config.Config{IgnoreDirs: []string{"**/Exports/**", "tmp*"}})
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{"a", false},
+		{".cache", true},
+		{"out", true},
+		{"out/20260101", true},
+		{"tmp-render", true},
+		{"Projects/Exports/final", true},
+		{"Projects/Raw", false},
+	}
+	for _, tt := range tests {
+		if got := w.ignoredDir(filepath.Join(root, tt.dir)); got != tt.want {
+			t.Errorf("ignoredDir(%s) = %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package watcher
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 
@@ -19,71 +21,307 @@ type Watcher struct {
 	Cfg       *config.Config
 	Converter *convert.Converter
 	EventChan chan<- interface{} // Optional: Send events for TUI
+
+	// ConfigPath is reloaded when it changes (optional, see reload)
+	ConfigPath string
+	// Reload reads the config again the way Cfg was built (config file, flags and
+	// watch targets). nil disables hot reload, including SIGHUP.
+	Reload func() (*config.Config, error)
+
+	mu       sync.RWMutex // guards Cfg, Converter, settings, roots and health
+	settings settings
+	roots    []string              // absolute watch dirs
+	globbed  map[string]bool       // roots matched by a watchDirs pattern
+	health   map[string]*rootState // per root, see rootState
+	started  time.Time
+
+	statusPath string          // status file for doctor ("" = not written)
+	done       <-chan struct{} // Done of the ctx passed to Run (see emit)
+
+	fsw       *fsnotify.Watcher
+	poll      *poller // watch dirs on filesystems without change notifications
+	queue     *Queue
+	stable    *stabilityDetector
+	jobs      sync.WaitGroup       // queue workers, scans and the stability detector
+	workers   []context.CancelFunc // stops the queue workers (event loop only)
+	stopScans context.CancelFunc   // stops the periodic scan (event loop only)
 }
 
 func New(cfg *config.Config, cvt *convert.Converter) *Watcher {
 	return &Watcher{
 		Cfg:       cfg,
 		Converter: cvt,
+		health:    map[string]*rootState{},
 	}
 }
 
-func (w *Watcher) Run() {
+// Run watches the configured directories until ctx is cancelled.
+// On cancellation running conversions are stopped (ffmpeg is killed and partial
+// outputs are removed) and Run returns ctx.Err() once they have finished.
+func (w *Watcher) Run(ctx context.Context) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer watcher.Close()
+	w.fsw = watcher
+	w.done = ctx.Done()
 
-	if len(w.Cfg.WatchDirs) == 0 {
-		log.Fatal("監視対象のディレクトリが設定されていません")
+	cfg := w.conf()
+	if len(cfg.WatchDirs) == 0 {
+		return fmt.Errorf("監視対象のディレクトリが設定されていません")
+	}
+	set, err := parseSettings(cfg)
+	if err != nil {
+		return err
 	}
+	w.settings = set
 
-	done := make(chan bool)
+	// Forward live ffmpeg progress to the TUI
+	w.Converter.OnProgress = w.emitProgress
 
-	// 重複処理防止用のマップ
-	var processingMu sync.Mutex
-	processing := make(map[string]bool)
+	// Detected files go through a journal under the state dir, so that pending and
+	// interrupted jobs survive a crash or reboot and are replayed here.
+	queue, err := OpenQueue(w.queuePath())
+	if err != nil {
+		return fmt.Errorf("ジョブキューを開けません: %w", err)
+	}
+	defer queue.Close()
+	w.queue = queue
+
+	// Watch dir states for doctor, removed again when watch mode stops
+	w.started = time.Now()
+	w.statusPath = StatusPath(cfg)
+	defer os.Remove(w.statusPath)
 
+	// New files are queued once they are completely written
+	w.stable = newStabilityDetector(set.stableWindow, cfg.CheckMoov)
+	w.jobs.Add(1)
 	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				w.handleEvent(event, &processingMu, processing)
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				log.Println("監視エラー:", err)
+		defer w.jobs.Done()
+		w.stable.run(ctx, w.enqueue)
+	}()
+
+	// Polled directories report through the same path as fsnotify events
+	w.poll = newPoller(set.pollInterval, cfg.Recursive)
+	w.poll.skipDir = w.ignoredDir
+	w.poll.onCreate = w.detect
+	w.poll.onWrite = w.stable.touch
+	w.jobs.Add(1)
+	go func() {
+		defer w.jobs.Done()
+		w.poll.run(ctx)
+	}()
+
+	w.setWorkers(ctx, w.workerCount())
+
+	// Patterns in watchDirs are expanded now and again every globInterval
+	dirs, globbed := w.resolveWatchDirs()
+	w.globbed = globbed
+	for _, dir := range dirs {
+		w.addRoot(dir)
+	}
+	if w.hasPatterns() {
+		log.Printf("🔍 watchDirs のパターンを %v ごとに再評価します (一致したフォルダ: %d個)", set.globInterval, len(globbed))
+	}
+	log.Printf("同時変換数: %d", len(w.workers))
+
+	// Catch up on files that arrived while the watcher was not running
+	if !cfg.NoScan {
+		if n := w.scan(w.watchRoots()); n > 0 {
+			log.Printf("🔎 起動時スキャンで未変換のファイルを %d件 見つけました", n)
+		}
+	}
+	w.startScans(ctx)
+
+	reloads := w.watchReloads(ctx)
+	heal := time.NewTicker(healInterval)
+	defer heal.Stop()
+	globs := time.NewTimer(set.globInterval)
+	defer globs.Stop()
+
+	var runErr error
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			runErr = ctx.Err()
+			break loop
+		case event, ok := <-watcher.Events:
+			if !ok {
+				runErr = fmt.Errorf("fsnotify のイベントチャネルが閉じられました")
+				break loop
 			}
+			w.handleEvent(event)
+		case <-reloads:
+			w.reload(ctx)
+		case <-heal.C:
+			w.checkRoots()
+		case <-globs.C:
+			if w.hasPatterns() {
+				w.syncRoots(false)
+			}
+			w.mu.RLock()
+			globs.Reset(w.settings.globInterval)
+			w.mu.RUnlock()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				runErr = fmt.Errorf("fsnotify のエラーチャネルが閉じられました")
+				break loop
+			}
+			log.Println("監視エラー:", err)
 		}
-	}()
+	}
+
+	// Wait for in-flight conversions (they see the cancelled ctx and clean up)
+	log.Println("🛑 監視を停止しています...")
+	w.jobs.Wait()
+	return runErr
+}
+
+// queuePath is the journal of the watch-mode job queue.
+func (w *Watcher) queuePath() string {
+	return filepath.Join(w.conf().StatePath(), "watch-queue.jsonl")
+}
+
+// conf returns the current config.
+func (w *Watcher) conf() *config.Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.Cfg
+}
+
+// converter returns the converter for the current config.
+func (w *Watcher) converter() *convert.Converter {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.Converter
+}
 
-	for _, dir := range w.Cfg.WatchDirs {
-		absDir, err := filepath.Abs(dir)
+// workerCount is the number of files converted at once (watchWorkers, default concurrent).
+func (w *Watcher) workerCount() int {
+	if n := w.conf().WatchWorkers; n > 0 {
+		return n
+	}
+	return w.converter().Sched.Slots()
+}
+
+// setWorkers starts or stops queue workers until n are running. A stopped
+// worker finishes its current job first.
+func (w *Watcher) setWorkers(ctx context.Context, n int) {
+	for len(w.workers) < n {
+		quit, stop := context.WithCancel(ctx)
+		w.workers = append(w.workers, stop)
+		w.jobs.Add(1)
+		go func() {
+			defer w.jobs.Done()
+			w.runWorker(ctx, quit)
+		}()
+	}
+	for len(w.workers) > n {
+		last := len(w.workers) - 1
+		w.workers[last]()
+		w.workers = w.workers[:last]
+	}
+}
+
+// runWorker converts queued files until ctx is cancelled or quit is done.
+func (w *Watcher) runWorker(ctx, quit context.Context) {
+	for quit.Err() == nil {
+		job, err := w.queue.Next(quit)
 		if err != nil {
-			log.Printf("⚠️ ディレクトリパスの解決に失敗 (スキップ): %s -> %v", dir, err)
+			return
+		}
+		if _, err := os.Stat(job.Path); err != nil {
+			log.Printf("ファイルが見つかりません (削除または移動されました): %s", job.Path)
+			w.queue.Finish(job.Path, "", err)
 			continue
 		}
-		if err = watcher.Add(absDir); err != nil {
-			log.Printf("⚠️ 監視エラー (スキップ): %s -> %v", dir, err)
-		} else {
-			log.Printf("監視を開始しました: %s", absDir)
+
+		outPath, err := w.processFile(ctx, job.Path, filepath.Base(job.Path))
+		if ctx.Err() != nil {
+			// Replayed on the next start
+			w.queue.Requeue(job.Path)
+			return
 		}
+		w.queue.Finish(job.Path, outPath, err)
+	}
+}
+
+// settings are the watch-mode options of the config that need parsing.
+type settings struct {
+	stableWindow time.Duration
+	scanInterval time.Duration // 0 = scan on startup only
+	pollInterval time.Duration
+	globInterval time.Duration
+}
+
+func parseSettings(cfg *config.Config) (settings, error) {
+	var set settings
+	var err error
+	if set.stableWindow, err = parseDuration("stableWindow", cfg.StableWindow, defaultStableWindow); err != nil {
+		return set, err
+	}
+	if set.scanInterval, err = parseDuration("scanInterval", cfg.ScanInterval, 0); err != nil {
+		return set, err
+	}
+	if set.pollInterval, err = parseDuration("pollInterval", cfg.PollInterval, defaultPollInterval); err != nil {
+		return set, err
+	}
+	if set.globInterval, err = parseDuration("globInterval", cfg.GlobInterval, defaultGlobInterval); err != nil {
+		return set, err
 	}
+	if err := checkPatterns(cfg.WatchDirs); err != nil {
+		return set, err
+	}
+	return set, checkBackend(cfg.WatchBackend)
+}
 
-	<-done
+// parseDuration parses a positive duration option, returning def if it is empty.
+func parseDuration(key, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s の指定が不正です (例: 30s, 10m): %s", key, value)
+	}
+	return d, nil
 }
 
-func (w *Watcher) handleEvent(event fsnotify.Event, processingMu *sync.Mutex, processing map[string]bool) {
+// handleEvent passes new files to the stability detector. Write events only
+// restart the wait of files that are already pending.
+func (w *Watcher) handleEvent(event fsnotify.Event) {
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		if w.isRootEvent(event.Name) {
+			w.rootLost(filepath.Clean(event.Name), errors.New("削除または移動されました"))
+			return
+		}
+	}
+	if event.Op&fsnotify.Write == fsnotify.Write {
+		w.stable.touch(event.Name)
+	}
 	if event.Op&fsnotify.Create != fsnotify.Create && event.Op&fsnotify.Rename != fsnotify.Rename {
 		return
 	}
 
-	fName := filepath.Base(event.Name)
+	info, err := os.Stat(event.Name)
+	if err != nil {
+		// Rename also reports the old name, which is gone
+		return
+	}
+	if info.IsDir() {
+		if w.conf().Recursive {
+			w.addNewDir(event.Name)
+		}
+		return
+	}
+	w.detect(event.Name)
+}
+
+// detect applies the file filters and hands matching videos to the stability detector.
+func (w *Watcher) detect(path string) {
+	fName := filepath.Base(path)
 	if strings.HasPrefix(fName, ".") {
 		return
 	}
@@ -96,32 +334,23 @@ func (w *Watcher) handleEvent(event fsnotify.Event, processingMu *sync.Mutex, pr
 		return
 	}
 
-	log.Printf("新規ファイルを検知: %s", event.Name)
+	log.Printf("新規ファイルを検知: %s", path)
 
-	if w.EventChan != nil {
-		// Emit Found Event
-		// Use anonymous struct or map to avoid dep?
-		// Or define Types in watcher pkg
-		w.EventChan <- FileFoundEvent{Path: event.Name, Name: fName}
-	}
+	w.emit(FileFoundEvent{Path: path, Name: fName})
 
-	time.Sleep(2 * time.Second) // Wait for write finish (simple)
+	w.stable.add(path)
+}
 
-	if _, err := os.Stat(event.Name); os.IsNotExist(err) {
-		log.Printf("ファイルが見つかりません (削除または移動されました): %s", event.Name)
+// enqueue adds a completely written file to the job queue.
+func (w *Watcher) enqueue(path string) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		log.Printf("パスの解決に失敗: %v", err)
 		return
 	}
-
-	processingMu.Lock()
-	if processing[event.Name] {
-		processingMu.Unlock()
-		log.Printf("すでに処理中です: %s", event.Name)
-		return
+	if !w.queue.Enqueue(absPath) {
+		log.Printf("すでに処理中です: %s", path)
 	}
-	processing[event.Name] = true
-	processingMu.Unlock()
-
-	go w.processFile(event.Name, fName, processingMu, processing)
 }
 
 // Events
@@ -141,6 +370,43 @@ type FailureEvent struct {
 	Err  error
 }
 
+// ConfigReloadedEvent is sent after the config was reloaded.
+type ConfigReloadedEvent struct {
+	WatchDirs []string
+	Changes   []string // "key: old → new"
+}
+type ProgressEvent struct {
+	Path    string
+	Percent float64 // -1 if unknown
+	Speed   float64
+	ETA     time.Duration
+}
+
+// emit sends an event to EventChan. It gives up once Run's ctx is cancelled, so
+// that a consumer which stopped reading (the TUI after quitting) cannot block
+// the event loop, the workers or shutdown.
+func (w *Watcher) emit(ev interface{}) {
+	if w.EventChan == nil {
+		return
+	}
+	select {
+	case w.EventChan <- ev:
+	case <-w.done:
+	}
+}
+
+// emitProgress forwards converter progress to EventChan without blocking ffmpeg
+// (progress is dropped if the consumer is behind; the next update will catch up).
+func (w *Watcher) emitProgress(p convert.Progress) {
+	if w.EventChan == nil {
+		return
+	}
+	select {
+	case w.EventChan <- ProgressEvent{Path: p.Input, Percent: p.Percent, Speed: p.Speed, ETA: p.ETA}:
+	default:
+	}
+}
+
 func (w *Watcher) isTargetVideo(fName string) bool {
 	ext := strings.ToLower(filepath.Ext(fName))
 	for _, v := range []string{".mov", ".mp4", ".m4v", ".avi", ".mkv"} {
@@ -152,79 +418,89 @@ func (w *Watcher) isTargetVideo(fName string) bool {
 }
 
 func (w *Watcher) shouldProcess(fName string) bool {
+	ok, reason := w.keywordFilter(fName)
+	if !ok {
+		log.Printf("%s: %s", reason, fName)
+	}
+	return ok
+}
+
+// keywordFilter applies keywords / ignoreKeywords and returns why a file is skipped.
+func (w *Watcher) keywordFilter(fName string) (bool, string) {
+	cfg := w.conf()
 	lowerName := strings.ToLower(fName)
 	// Exclude
-	if len(w.Cfg.IgnoreKeywords) > 0 {
-		for _, k := range w.Cfg.IgnoreKeywords {
+	if len(cfg.IgnoreKeywords) > 0 {
+		for _, k := range cfg.IgnoreKeywords {
 			if strings.Contains(lowerName, strings.ToLower(k)) {
-				log.Printf("無視キーワードに一致したためスキップ: %s", fName)
-				return false
+				return false, "無視キーワードに一致したためスキップ"
 			}
 		}
 	}
 
 	// Include
-	if len(w.Cfg.Keywords) > 0 {
+	if len(cfg.Keywords) > 0 {
 		included := false
-		for _, k := range w.Cfg.Keywords {
+		for _, k := range cfg.Keywords {
 			if strings.Contains(lowerName, strings.ToLower(k)) {
 				included = true
 				break
 			}
 		}
 		if !included {
-			log.Printf("キーワードに一致しないためスキップ: %s", fName)
-			return false
+			return false, "キーワードに一致しないためスキップ"
 		}
 	}
-	return true
+	return true, ""
 }
 
-func (w *Watcher) processFile(path, name string, processingMu *sync.Mutex, processing map[string]bool) {
-	defer func() {
-		processingMu.Lock()
-		delete(processing, path)
-		processingMu.Unlock()
-	}()
+// processFile converts one file and reports the result via log, EventChan and
+// notifications. path is absolute. The job keeps the config it started with,
+// even if it is reloaded meanwhile.
+func (w *Watcher) processFile(ctx context.Context, path, name string) (string, error) {
+	w.mu.RLock()
+	cfg, cvt := w.Cfg, w.Converter
+	w.mu.RUnlock()
 
-	baseOut, _ := filepath.Abs(w.Cfg.DestDir)
+	baseOut, _ := filepath.Abs(cfg.DestDir)
 	batchDir := baseOut
-	if w.Cfg.BatchStamp {
+	if cfg.BatchStamp {
 		batchDir = filepath.Join(baseOut, nowStamp())
 	}
 	if err := os.MkdirAll(batchDir, 0755); err != nil {
 		log.Printf("出力ディレクトリ作成失敗: %v", err)
-		return
+		return "", err
 	}
 
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		log.Printf("パスの解決に失敗: %v", err)
-		return
-	}
-
-	log.Printf("変換開始: %s", absPath)
-	if w.EventChan != nil {
-		w.EventChan <- StartConvertEvent{Path: absPath}
-	}
+	log.Printf("変換開始: %s", path)
+	w.emit(StartConvertEvent{Path: path})
 
-	if outPath, err := w.Converter.Convert(absPath, batchDir); err != nil {
-		log.Printf("❌ 変換失敗: %v", err)
-		if w.EventChan != nil {
-			w.EventChan <- FailureEvent{Path: absPath, Err: err}
-		}
-		if w.Cfg.Notify {
-			convert.SendNotification("変換失敗", fmt.Sprintf("%s の変換に失敗しました。", name), "")
+	outPath, err := cvt.Convert(ctx, path, batchDir)
+	if err != nil {
+		if ctx.Err() != nil {
+			// The consumer (TUI) may already be gone, so no event here
+			log.Printf("🛑 変換を中断しました: %s", path)
+			return "", err
 		}
-	} else {
-		log.Printf("✅ 変換完了: %s", path)
-		if w.EventChan != nil {
-			w.EventChan <- SuccessEvent{Path: path, OutPath: outPath}
+		if errors.Is(err, convert.ErrOutputExists) {
+			log.Printf("⏭ %v", err)
+			w.emit(FailureEvent{Path: path, Err: err})
+			return "", err
 		}
-		if w.Cfg.Notify {
-			convert.SendNotification("変換完了", fmt.Sprintf("%s を変換しました。", name), outPath)
+		log.Printf("❌ 変換失敗: %v", err)
+		w.emit(FailureEvent{Path: path, Err: err})
+		if cfg.Notify {
+			convert.SendNotification("変換失敗", fmt.Sprintf("%s の変換に失敗しました。", name), "")
 		}
+		return "", err
+	}
+
+	log.Printf("✅ 変換完了: %s", path)
+	w.emit(SuccessEvent{Path: path, OutPath: outPath})
+	if cfg.Notify {
+		convert.SendNotification("変換完了", fmt.Sprintf("%s を変換しました。", name), outPath)
 	}
+	return outPath, nil
 }
 
 func nowStamp() string {
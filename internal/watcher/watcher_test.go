@@ -3,6 +3,7 @@ package watcher
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mt4110/rec-watch/internal/config"
 )
@@ -89,3 +90,21 @@ func TestFiltering(t *testing.T) {
 		})
 	}
 }
+
+func TestEmitStopsWhenCancelled(t *testing.T) {
+	events := make(chan interface{}) // nobody reads, like the TUI after quitting
+	done := make(chan struct{})
+	w := &Watcher{EventChan: events, done: done}
+
+	sent := make(chan struct{})
+	go func() {
+		w.emit(SuccessEvent{Path: "a.mov"})
+		close(sent)
+	}()
+	close(done)
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("emit blocked after cancellation")
+	}
+}